@@ -2,10 +2,14 @@ package elastic
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	es "github.com/olivere/elastic/v7"
 )
@@ -22,10 +26,20 @@ type (
 	ScrollService struct {
 		helper *es.ScrollService
 	}
+
+	retrier struct {
+		maxRetries int
+		backoff    es.Backoff
+		maxWait    time.Duration
+	}
 )
 
-func newElastic(addr string) (*ES, error) {
-	client, err := es.NewClient(es.SetURL(addr), es.SetSniff(false))
+func newElastic(conf Config) (*ES, error) {
+	opts, err := clientOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	client, err := es.NewClient(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +49,115 @@ func newElastic(addr string) (*ES, error) {
 	}, nil
 }
 
+func clientOptions(conf Config) ([]es.ClientOptionFunc, error) {
+	opts := []es.ClientOptionFunc{
+		es.SetURL(conf.URLs()...),
+		es.SetSniff(conf.Sniff.Enable),
+		es.SetHealthcheck(!conf.Healthcheck.Disable),
+		es.SetGzip(conf.Gzip),
+	}
+	if conf.Sniff.Enable {
+		if conf.Sniff.Interval > 0 {
+			opts = append(opts, es.SetSnifferInterval(time.Duration(conf.Sniff.Interval)))
+		}
+		if conf.Sniff.Timeout > 0 {
+			opts = append(opts, es.SetSnifferTimeout(time.Duration(conf.Sniff.Timeout)))
+		}
+	}
+	if !conf.Healthcheck.Disable {
+		if conf.Healthcheck.Interval > 0 {
+			opts = append(opts, es.SetHealthcheckInterval(time.Duration(conf.Healthcheck.Interval)))
+		}
+		if conf.Healthcheck.Timeout > 0 {
+			opts = append(opts, es.SetHealthcheckTimeout(time.Duration(conf.Healthcheck.Timeout)))
+		}
+	}
+	if conf.Auth.Enable {
+		if conf.Auth.APIKey != "" {
+			headers := http.Header{}
+			headers.Set("Authorization", "ApiKey "+conf.Auth.APIKey)
+			opts = append(opts, es.SetHeaders(headers))
+		} else {
+			opts = append(opts, es.SetBasicAuth(conf.Auth.Username, conf.Auth.Password))
+		}
+	}
+	if conf.TLS.Enable {
+		tlsConf, err := newTLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConf
+		opts = append(opts, es.SetScheme("https"), es.SetHttpClient(&http.Client{Transport: transport}))
+	}
+	if conf.Retry.Enable {
+		opts = append(opts, es.SetRetrier(newRetrier(conf.Retry)))
+		if len(conf.Retry.StatusCodes) > 0 {
+			opts = append(opts, es.SetRetryStatusCodes(conf.Retry.StatusCodes...))
+		}
+	}
+	// Success
+	return opts, nil
+}
+
+func newTLSConfig(conf TLSConfig) (*tls.Config, error) {
+	tlsConf := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify}
+	if conf.CAFile != "" {
+		bts, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bts) {
+			return nil, errors.New(InvalidCACertificate)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	// Success
+	return tlsConf, nil
+}
+
+func newRetrier(conf RetryConfig) *retrier {
+	if conf.MaxRetries <= 0 {
+		conf.MaxRetries = DefaultRetryMaxRetries
+	}
+	if conf.InitialInterval <= 0 {
+		conf.InitialInterval = DefaultRetryInitialInterval
+	}
+	if conf.MaxInterval <= 0 {
+		conf.MaxInterval = DefaultRetryMaxInterval
+	}
+	// Success
+	return &retrier{
+		maxRetries: conf.MaxRetries,
+		backoff:    es.NewExponentialBackoff(time.Duration(conf.InitialInterval), time.Duration(conf.MaxInterval)),
+		maxWait:    time.Duration(conf.MaxInterval),
+	}
+}
+
+func (r *retrier) Retry(ctx context.Context, retry int, _ *http.Request, _ *http.Response, _ error) (time.Duration, bool, error) {
+	if retry > r.maxRetries {
+		return 0, false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	wait, ok := r.backoff.Next(retry)
+	if !ok {
+		// Backoff reached its ceiling, keep retrying at the max interval
+		wait = r.maxWait
+	}
+	// Success
+	return wait, true, nil
+}
+
 func (con *ES) Bulk() *BulkService {
 	// Success
 	return &BulkService{helper: es.NewBulkService(con.model)}
@@ -72,12 +195,12 @@ func (bs *BulkService) Delete(index, id string) {
 
 func (bs *BulkService) Do() error {
 	defer bs.helper.Reset()
-	_, err := bs.helper.Refresh("true").Do(context.Background())
+	res, err := bs.helper.Refresh("true").Do(context.Background())
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 	// Success
-	return nil
+	return bulkError(res)
 }
 
 func (con *ES) Scroll() *ScrollService {
@@ -97,12 +220,15 @@ func (ss *ScrollService) Search(index string, query Query, sorts []string, size
 		}
 	}
 	result, err := service.Do(context.Background())
+	if err == io.EOF {
+		// The scroll has no more hits
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		if err == io.EOF {
-			return nil, errors.New(NotFoundError)
-		}
-		if ex := err.(*es.Error); ex.Status == http.StatusBadRequest || ex.Status == http.StatusNotFound {
-			return nil, errors.New(NotFoundError)
+		err = wrapError(err)
+		// An expired or cleared scroll ends the scroll as well
+		if errors.Is(err, ErrBadRequest) || errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
@@ -111,30 +237,34 @@ func (ss *ScrollService) Search(index string, query Query, sorts []string, size
 }
 
 func (con *ES) IndexExists(index string) (bool, error) {
-	// Success
-	return con.model.IndexExists(index).
+	exists, err := con.model.IndexExists(index).
 		Do(context.Background())
+	// Success
+	return exists, wrapError(err)
 }
 
 func (con *ES) CreateIndex(index string, mapping M) (*es.IndicesCreateResult, error) {
-	// Success
-	return con.model.CreateIndex(index).
+	res, err := con.model.CreateIndex(index).
 		BodyJson(mapping).
 		Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) DeleteIndex(index string) (*es.IndicesDeleteResponse, error) {
-	// Success
-	return con.model.DeleteIndex(index).
+	res, err := con.model.DeleteIndex(index).
 		Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) Count(index string, query Query) (int64, error) {
-	// Success
-	return con.model.Count().
+	count, err := con.model.Count().
 		Index(index).
 		Query(query).
 		Do(context.Background())
+	// Success
+	return count, wrapError(err)
 }
 
 func (con *ES) SearchScroll(index string, query Query, sorts []string, site int, scrollID, keepAlive string) (*es.SearchResult, error) {
@@ -148,85 +278,190 @@ func (con *ES) SearchPaging(index string, query Query, sorts []string, page, siz
 }
 
 func (con *ES) SearchOffset(index string, query Query, sorts []string, offset, size int) (*es.SearchResult, error) {
-	service := con.model.Search().Index(index)
+	// Success
+	return con.SearchWithOptions(index, query, sorts, offset, size, SearchOptions{})
+}
+
+func (con *ES) SearchWithOptions(index string, query Query, sorts []string, offset, size int, opts SearchOptions) (*es.SearchResult, error) {
+	result, err := con.model.Search().
+		Index(index).
+		SearchSource(newSearchSource(query, sorts, offset, size, opts)).
+		Do(context.Background())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	// Success
+	return result, nil
+}
+
+func (con *ES) MultiGet(index string, ids []string) (*es.MgetResponse, error) {
+	service := con.model.Mget().Realtime(true)
+	for _, id := range ids {
+		service = service.Add(es.NewMultiGetItem().Index(index).Id(id))
+	}
+	res, err := service.Do(context.Background())
+	// Success
+	return res, wrapError(err)
+}
+
+func (con *ES) MultiSearch(requests []SearchRequest) (*es.MultiSearchResult, error) {
+	service := con.model.MultiSearch()
+	for _, req := range requests {
+		service = service.Add(es.NewSearchRequest().
+			Index(req.Database).
+			SearchSource(newSearchSource(req.Query, req.Sorts, req.Offset, req.Size, req.Options)))
+	}
+	res, err := service.Do(context.Background())
+	// Success
+	return res, wrapError(err)
+}
+
+func newSearchSource(query Query, sorts []string, offset, size int, opts SearchOptions) *es.SearchSource {
+	source := es.NewSearchSource()
 	if sorts != nil && len(sorts) > 0 {
 		for _, sort := range sorts {
 			if strings.HasPrefix(sort, "-") {
-				service = service.Sort(strings.TrimPrefix(sort, "-"), false)
+				source = source.Sort(strings.TrimPrefix(sort, "-"), false)
 			} else if strings.HasPrefix(sort, "+") {
-				service = service.Sort(strings.TrimPrefix(sort, "+"), true)
+				source = source.Sort(strings.TrimPrefix(sort, "+"), true)
 			}
 		}
 	}
-	service = service.Query(query)
+	if query != nil {
+		source = source.Query(query)
+	}
 	if size == 0 {
 		size = 10
 	}
-	service = service.Size(size).From(offset)
-	result, err := service.Do(context.Background())
-	if err != nil {
-		if ex := err.(*es.Error); ex.Status == http.StatusBadRequest || ex.Status == http.StatusNotFound {
-			return nil, errors.New(NotFoundError)
-		}
-		return nil, err
+	source = source.Size(size).From(offset).SeqNoAndPrimaryTerm(true)
+	if len(opts.Includes) > 0 || len(opts.Excludes) > 0 {
+		source = source.FetchSourceContext(es.NewFetchSourceContext(true).
+			Include(opts.Includes...).
+			Exclude(opts.Excludes...))
+	}
+	if opts.Highlight != nil && len(opts.Highlight.Fields) > 0 {
+		source = source.Highlight(newHighlight(opts.Highlight))
+	}
+	if opts.Collapse != "" {
+		source = source.Collapse(es.NewCollapseBuilder(opts.Collapse))
+	}
+	if opts.MinScore > 0 {
+		source = source.MinScore(opts.MinScore)
 	}
 	// Success
-	return result, nil
+	return source
 }
 
-func (con *ES) Get(index, id string) (*es.GetResult, error) {
+func newHighlight(opts *HighlightOptions) *es.Highlight {
+	highlight := es.NewHighlight()
+	for _, field := range opts.Fields {
+		highlight = highlight.Field(field)
+	}
+	if len(opts.PreTags) > 0 {
+		highlight = highlight.PreTags(opts.PreTags...)
+	}
+	if len(opts.PostTags) > 0 {
+		highlight = highlight.PostTags(opts.PostTags...)
+	}
+	if opts.FragmentSize > 0 {
+		highlight = highlight.FragmentSize(opts.FragmentSize)
+	}
+	if opts.NumberOfFragments > 0 {
+		highlight = highlight.NumOfFragments(opts.NumberOfFragments)
+	}
 	// Success
-	return con.model.Get().
+	return highlight
+}
+
+func (con *ES) Get(index, id string) (*es.GetResult, error) {
+	res, err := con.model.Get().
 		Index(index).
 		Id(id).
 		Refresh("true").
 		Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) Exists(index, id string) (bool, error) {
-	// Success
-	return con.model.Exists().
+	exists, err := con.model.Exists().
 		Index(index).
 		Id(id).
 		Refresh("true").
 		Do(context.Background())
+	// Success
+	return exists, wrapError(err)
 }
 
 func (con *ES) Index(index string, doc Document) (*es.IndexResponse, error) {
 	// Success
-	return con.model.Index().
+	return con.IndexWithOptions(index, doc, WriteOptions{})
+}
+
+func (con *ES) IndexWithOptions(index string, doc Document, opts WriteOptions) (*es.IndexResponse, error) {
+	service := con.model.Index().
 		Index(index).
 		Id(doc.GetID()).
 		BodyJson(doc).
-		Refresh("true").
-		Do(context.Background())
+		Refresh("true")
+	if opts.Version != nil {
+		service = service.IfSeqNo(opts.Version.SeqNo).IfPrimaryTerm(opts.Version.PrimaryTerm)
+	}
+	res, err := service.Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) Update(index, id string, update interface{}, upsert bool) (*es.UpdateResponse, error) {
 	// Success
-	return con.model.Update().
+	return con.UpdateWithOptions(index, id, update, WriteOptions{Upsert: upsert})
+}
+
+func (con *ES) UpdateWithOptions(index, id string, update interface{}, opts WriteOptions) (*es.UpdateResponse, error) {
+	if opts.Version != nil && opts.RetryOnConflict > 0 {
+		return nil, ErrBadRequest
+	}
+	service := con.model.Update().
 		Index(index).
 		Id(id).
 		Doc(update).
-		DocAsUpsert(upsert).
-		Refresh("true").
-		Do(context.Background())
+		DocAsUpsert(opts.Upsert).
+		Refresh("true")
+	if opts.Version != nil {
+		service = service.IfSeqNo(opts.Version.SeqNo).IfPrimaryTerm(opts.Version.PrimaryTerm)
+	}
+	if opts.RetryOnConflict > 0 {
+		service = service.RetryOnConflict(opts.RetryOnConflict)
+	}
+	res, err := service.Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) DeleteByID(index, id string) (*es.DeleteResponse, error) {
 	// Success
-	return con.model.Delete().
+	return con.DeleteByIDWithOptions(index, id, WriteOptions{})
+}
+
+func (con *ES) DeleteByIDWithOptions(index, id string, opts WriteOptions) (*es.DeleteResponse, error) {
+	service := con.model.Delete().
 		Index(index).
 		Id(id).
-		Refresh("true").
-		Do(context.Background())
+		Refresh("true")
+	if opts.Version != nil {
+		service = service.IfSeqNo(opts.Version.SeqNo).IfPrimaryTerm(opts.Version.PrimaryTerm)
+	}
+	res, err := service.Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
 
 func (con *ES) DeleteByQuery(index string, query Query) (*es.BulkIndexByScrollResponse, error) {
-	// Success
-	return con.model.DeleteByQuery().
+	res, err := con.model.DeleteByQuery().
 		Index(index).
 		Query(query).
 		Refresh("true").
 		Do(context.Background())
+	// Success
+	return res, wrapError(err)
 }
@@ -1,10 +1,82 @@
 package elastic
 
-type Config struct {
-	Address string `json:"address" yaml:"address"`
-}
+import (
+	"strings"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	Config struct {
+		Address     string            `json:"address" yaml:"address"`
+		Addresses   []string          `json:"addresses" yaml:"addresses"`
+		Auth        AuthConfig        `json:"auth" yaml:"auth"`
+		TLS         TLSConfig         `json:"tls" yaml:"tls"`
+		Sniff       SniffConfig       `json:"sniff" yaml:"sniff"`
+		Healthcheck HealthcheckConfig `json:"healthcheck" yaml:"healthcheck"`
+		Retry       RetryConfig       `json:"retry" yaml:"retry"`
+		Gzip        bool              `json:"gzip" yaml:"gzip"`
+	}
+
+	AuthConfig struct {
+		Enable   bool   `json:"enable" yaml:"enable"`
+		Username string `json:"username" yaml:"username"`
+		Password string `json:"password" yaml:"password"`
+		APIKey   string `json:"api_key" yaml:"api_key"`
+	}
+
+	TLSConfig struct {
+		Enable             bool   `json:"enable" yaml:"enable"`
+		CAFile             string `json:"ca_file" yaml:"ca_file"`
+		CertFile           string `json:"cert_file" yaml:"cert_file"`
+		KeyFile            string `json:"key_file" yaml:"key_file"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	}
+
+	SniffConfig struct {
+		Enable   bool           `json:"enable" yaml:"enable"`
+		Interval clock.Duration `json:"interval" yaml:"interval"`
+		Timeout  clock.Duration `json:"timeout" yaml:"timeout"`
+	}
 
+	// HealthcheckConfig is enabled by default, as the client always was
+	HealthcheckConfig struct {
+		Disable  bool           `json:"disable" yaml:"disable"`
+		Interval clock.Duration `json:"interval" yaml:"interval"`
+		Timeout  clock.Duration `json:"timeout" yaml:"timeout"`
+	}
+
+	RetryConfig struct {
+		Enable          bool           `json:"enable" yaml:"enable"`
+		MaxRetries      int            `json:"max_retries" yaml:"max_retries"`
+		InitialInterval clock.Duration `json:"initial_interval" yaml:"initial_interval"`
+		MaxInterval     clock.Duration `json:"max_interval" yaml:"max_interval"`
+		StatusCodes     []int          `json:"status_codes" yaml:"status_codes"`
+	}
+)
+
+var (
+	DefaultRetryMaxRetries      = 3
+	DefaultRetryInitialInterval = 100 * clock.Millisecond
+	DefaultRetryMaxInterval     = 10 * clock.Second
+)
+
+// String lists every endpoint, comma separated
 func (conf *Config) String() string {
 	// Success
-	return conf.Address
+	return strings.Join(conf.URLs(), ",")
+}
+
+func (conf *Config) URLs() []string {
+	urls := make([]string, 0)
+	if conf.Address != "" {
+		urls = append(urls, conf.Address)
+	}
+	for _, addr := range conf.Addresses {
+		if addr != "" && addr != conf.Address {
+			urls = append(urls, addr)
+		}
+	}
+	// Success
+	return urls
 }
@@ -1,5 +1,11 @@
 package elastic
 
+import (
+	"encoding/json"
+
+	es "github.com/olivere/elastic/v7"
+)
+
 type (
 	// Map
 	M map[string]interface{}
@@ -24,3 +30,110 @@ func (doc Doc) GetID() string {
 func (q Query) Source() (interface{}, error) {
 	return q, nil
 }
+
+type (
+	// Search
+	SearchOptions struct {
+		Includes  []string
+		Excludes  []string
+		Highlight *HighlightOptions
+		Collapse  string
+		MinScore  float64
+	}
+
+	HighlightOptions struct {
+		Fields            []string
+		PreTags           []string
+		PostTags          []string
+		FragmentSize      int
+		NumberOfFragments int
+	}
+
+	SearchResult struct {
+		Total int64
+		Hits  []Hit
+	}
+
+	Hit struct {
+		Index     string
+		ID        string
+		Score     *float64
+		Sort      []interface{}
+		Highlight map[string][]string
+		Version   *Version
+	}
+
+	SearchRequest struct {
+		Database   string
+		Collection string
+		Query      Query
+		Sorts      []string
+		Offset     int
+		Size       int
+		Options    SearchOptions
+		Results    interface{}
+	}
+
+	SearchResponse struct {
+		SearchResult
+		Err error
+	}
+
+	// Multi get
+	GetResult struct {
+		Index   string
+		ID      string
+		Found   bool
+		Version *Version
+		Err     error
+	}
+
+	// Suggest
+	Suggestion struct {
+		Input  string
+		Text   string
+		Score  float64
+		Freq   int
+		Index  string
+		ID     string
+		Source json.RawMessage
+	}
+
+	// Concurrency
+	Version struct {
+		SeqNo       int64
+		PrimaryTerm int64
+	}
+
+	// RetryOnConflict only applies to partial updates without a Version,
+	// Elasticsearch rejects requests combining both so UpdateWithOptions
+	// returns ErrBadRequest without sending them
+	WriteOptions struct {
+		Version         *Version
+		Upsert          bool
+		RetryOnConflict int
+	}
+)
+
+func newHit(hit *es.SearchHit) Hit {
+	result := Hit{
+		Index: hit.Index,
+		ID:    hit.Id,
+		Score: hit.Score,
+		Sort:  hit.Sort,
+	}
+	if len(hit.Highlight) > 0 {
+		result.Highlight = hit.Highlight
+	}
+	result.Version = newVersion(hit.SeqNo, hit.PrimaryTerm)
+	// Success
+	return result
+}
+
+func newVersion(seqNo, primaryTerm *int64) *Version {
+	if seqNo == nil || primaryTerm == nil {
+		return nil
+	}
+	// Success
+	return &Version{SeqNo: *seqNo, PrimaryTerm: *primaryTerm}
+}
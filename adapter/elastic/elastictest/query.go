@@ -0,0 +1,654 @@
+package elastictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type (
+	sortField struct {
+		field string
+		desc  bool
+	}
+
+	hit struct {
+		doc   *document
+		score float64
+		sort  []interface{}
+	}
+)
+
+var fieldQueries = map[string]bool{
+	"term":                true,
+	"terms":               true,
+	"match":               true,
+	"match_phrase":        true,
+	"match_phrase_prefix": true,
+	"search_as_you_type":  true,
+	"prefix":              true,
+	"wildcard":            true,
+	"range":               true,
+}
+
+// subFields are multi-field suffixes resolved against their parent field
+var subFields = []string{".keyword", "._2gram", "._3gram", "._index_prefix"}
+
+// match evaluates the subset of the query DSL supported by the fake server:
+// match_all, match_none, term, terms, match, match_phrase, multi_match,
+// prefix, wildcard, range, exists, ids and bool
+func match(q interface{}, doc *document) (bool, *apiError) {
+	if q == nil {
+		return true, nil
+	}
+	body, ok := q.(map[string]interface{})
+	if !ok || len(body) != 1 {
+		return false, badRequest("parsing_exception", "query malformed, expected a single query type")
+	}
+	for kind, value := range body {
+		params, _ := value.(map[string]interface{})
+		switch kind {
+		case "match_all":
+			return true, nil
+		case "match_none":
+			return false, nil
+		case "bool":
+			return matchBool(params, doc)
+		case "ids":
+			for _, id := range asSlice(params["values"]) {
+				if fmt.Sprint(id) == doc.id {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "exists":
+			field, _ := params["field"].(string)
+			return len(lookup(doc, field)) > 0, nil
+		case "multi_match":
+			text := fmt.Sprint(params["query"])
+			operator, _ := params["operator"].(string)
+			prefix := params["type"] == "bool_prefix" || params["type"] == "phrase_prefix"
+			for _, field := range asSlice(params["fields"]) {
+				name := strings.SplitN(fmt.Sprint(field), "^", 2)[0]
+				values := lookup(doc, name)
+				if (prefix && matchPrefixText(values, text, operator)) || (!prefix && matchText(values, text, operator)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		if !fieldQueries[kind] {
+			return false, badRequest("parsing_exception", fmt.Sprintf("unknown query [%s]", kind))
+		}
+		field, arg, err := fieldParam(params)
+		if err != nil {
+			return false, err
+		}
+		values := lookup(doc, field)
+		switch kind {
+		case "term":
+			return containsValue(values, unwrap(arg, "value")), nil
+		case "terms":
+			for _, expected := range asSlice(arg) {
+				if containsValue(values, expected) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "match":
+			operator := ""
+			if options, ok := arg.(map[string]interface{}); ok {
+				operator, _ = options["operator"].(string)
+			}
+			return matchText(values, fmt.Sprint(unwrap(arg, "query")), operator), nil
+		case "match_phrase", "match_phrase_prefix", "search_as_you_type":
+			phrase := strings.ToLower(fmt.Sprint(unwrap(arg, "query")))
+			for _, value := range values {
+				if strings.Contains(strings.ToLower(fmt.Sprint(value)), phrase) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "prefix":
+			prefix := fmt.Sprint(unwrap(arg, "value"))
+			for _, value := range values {
+				if strings.HasPrefix(fmt.Sprint(value), prefix) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "wildcard":
+			pattern := fmt.Sprint(unwrap(arg, "value"))
+			for _, value := range values {
+				if ok, _ := path.Match(pattern, fmt.Sprint(value)); ok {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "range":
+			bounds, _ := arg.(map[string]interface{})
+			for _, value := range values {
+				if inRange(value, bounds) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	// Success
+	return false, nil
+}
+
+func matchBool(params map[string]interface{}, doc *document) (bool, *apiError) {
+	for _, key := range []string{"must", "filter"} {
+		for _, clause := range asSlice(params[key]) {
+			ok, err := match(clause, doc)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	for _, clause := range asSlice(params["must_not"]) {
+		ok, err := match(clause, doc)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+	should := asSlice(params["should"])
+	minimum := 0
+	if len(should) > 0 && len(asSlice(params["must"])) == 0 && len(asSlice(params["filter"])) == 0 {
+		minimum = 1
+	}
+	if value, ok := params["minimum_should_match"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprint(value)); err == nil {
+			minimum = n
+		}
+	}
+	matched := 0
+	for _, clause := range should {
+		ok, err := match(clause, doc)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			matched++
+		}
+	}
+	// Success
+	return matched >= minimum, nil
+}
+
+func fieldParam(params map[string]interface{}) (string, interface{}, *apiError) {
+	for field, arg := range params {
+		if field == "boost" || field == "_name" {
+			continue
+		}
+		return field, arg, nil
+	}
+	// Success
+	return "", nil, badRequest("parsing_exception", "query is missing a field")
+}
+
+func unwrap(arg interface{}, key string) interface{} {
+	if options, ok := arg.(map[string]interface{}); ok {
+		return options[key]
+	}
+	// Success
+	return arg
+}
+
+func asSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	// Success
+	return []interface{}{value}
+}
+
+// lookup resolves a dotted field path, flattening arrays along the way
+func lookup(doc *document, field string) []interface{} {
+	switch field {
+	case "_id":
+		return []interface{}{doc.id}
+	case "_index":
+		return []interface{}{doc.index}
+	}
+	values := lookupPath(doc.source, strings.Split(field, "."))
+	for _, suffix := range subFields {
+		if len(values) == 0 && strings.HasSuffix(field, suffix) {
+			values = lookupPath(doc.source, strings.Split(strings.TrimSuffix(field, suffix), "."))
+		}
+	}
+	// Success
+	return values
+}
+
+func lookupPath(value interface{}, parts []string) []interface{} {
+	if len(parts) == 0 {
+		if items, ok := value.([]interface{}); ok {
+			result := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				result = append(result, lookupPath(item, nil)...)
+			}
+			return result
+		}
+		if value == nil {
+			return nil
+		}
+		return []interface{}{value}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if child, ok := v[strings.Join(parts, ".")]; ok {
+			return lookupPath(child, nil)
+		}
+		return lookupPath(v[parts[0]], parts[1:])
+	case []interface{}:
+		result := make([]interface{}, 0)
+		for _, item := range v {
+			result = append(result, lookupPath(item, parts)...)
+		}
+		return result
+	}
+	// Success
+	return nil
+}
+
+func containsValue(values []interface{}, expected interface{}) bool {
+	for _, value := range values {
+		if compare(value, expected) == 0 {
+			return true
+		}
+	}
+	// Success
+	return false
+}
+
+func matchText(values []interface{}, text, operator string) bool {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return false
+	}
+	tokens := make(map[string]bool)
+	for _, value := range values {
+		for _, token := range tokenize(fmt.Sprint(value)) {
+			tokens[token] = true
+		}
+	}
+	matched := 0
+	for _, term := range terms {
+		if tokens[term] {
+			matched++
+		}
+	}
+	if strings.EqualFold(operator, "and") {
+		return matched == len(terms)
+	}
+	// Success
+	return matched > 0
+}
+
+// matchPrefixText treats the last query term as a prefix, like the
+// bool_prefix multi_match used for search_as_you_type fields
+func matchPrefixText(values []interface{}, text, operator string) bool {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return false
+	}
+	tokens := make([]string, 0)
+	for _, value := range values {
+		tokens = append(tokens, tokenize(fmt.Sprint(value))...)
+	}
+	matched := 0
+	for idx, term := range terms {
+		for _, token := range tokens {
+			if token == term || (idx == len(terms)-1 && strings.HasPrefix(token, term)) {
+				matched++
+				break
+			}
+		}
+	}
+	if strings.EqualFold(operator, "and") {
+		return matched == len(terms)
+	}
+	// Success
+	return matched > 0
+}
+
+func tokenize(text string) []string {
+	// Success
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func inRange(value interface{}, bounds map[string]interface{}) bool {
+	for op, bound := range bounds {
+		c := compare(value, bound)
+		switch op {
+		case "gt":
+			if c <= 0 {
+				return false
+			}
+		case "gte":
+			if c < 0 {
+				return false
+			}
+		case "lt":
+			if c >= 0 {
+				return false
+			}
+		case "lte":
+			if c > 0 {
+				return false
+			}
+		}
+	}
+	// Success
+	return true
+}
+
+// compare orders numbers numerically, everything else by its string form
+func compare(a, b interface{}) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	// Success
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	// Success
+	return 0, false
+}
+
+func parseSorts(value interface{}) []sortField {
+	result := make([]sortField, 0)
+	for _, item := range asSlice(value) {
+		switch v := item.(type) {
+		case string:
+			result = append(result, sortField{field: v, desc: v == "_score"})
+		case map[string]interface{}:
+			for field, options := range v {
+				order, ok := options.(string)
+				if !ok {
+					order, _ = unwrap(options, "order").(string)
+				}
+				desc := order == "desc" || (order == "" && field == "_score")
+				result = append(result, sortField{field: field, desc: desc})
+			}
+		}
+	}
+	// Success
+	return result
+}
+
+func sortHits(hits []*hit, sorts []sortField) {
+	for _, h := range hits {
+		h.sort = nil
+		for _, s := range sorts {
+			h.sort = append(h.sort, sortValue(h, s.field))
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		for idx, s := range sorts {
+			a, b := hits[i].sort[idx], hits[j].sort[idx]
+			if a == nil || b == nil {
+				if a == nil && b != nil {
+					return false
+				}
+				if a != nil && b == nil {
+					return true
+				}
+				continue
+			}
+			c := compare(a, b)
+			if c == 0 {
+				continue
+			}
+			if s.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return hits[i].doc.order < hits[j].doc.order
+	})
+}
+
+func sortValue(h *hit, field string) interface{} {
+	switch field {
+	case "_doc":
+		return h.doc.order
+	case "_score":
+		return h.score
+	}
+	values := lookup(h.doc, field)
+	if len(values) == 0 {
+		return nil
+	}
+	// Success
+	return values[0]
+}
+
+// filterSource applies _source includes/excludes, returning nil when the
+// source is disabled entirely
+func filterSource(doc *document, spec interface{}) json.RawMessage {
+	var includes, excludes []string
+	switch v := spec.(type) {
+	case nil:
+		return doc.raw
+	case bool:
+		if !v {
+			return nil
+		}
+		return doc.raw
+	case string:
+		includes = []string{v}
+	case []interface{}:
+		includes = toStrings(v)
+	case map[string]interface{}:
+		includes = toStrings(asSlice(v["includes"]))
+		excludes = toStrings(asSlice(v["excludes"]))
+	}
+	if len(includes) == 0 && len(excludes) == 0 {
+		return doc.raw
+	}
+	bts, err := json.Marshal(filterMap(doc.source, "", includes, excludes))
+	if err != nil {
+		return doc.raw
+	}
+	// Success
+	return bts
+}
+
+func filterMap(source map[string]interface{}, prefix string, includes, excludes []string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range source {
+		full := key
+		if prefix != "" {
+			full = prefix + "." + key
+		}
+		if matchAny(excludes, full) {
+			continue
+		}
+		if len(includes) == 0 || matchAny(includes, full) {
+			if child, ok := value.(map[string]interface{}); ok && len(excludes) > 0 {
+				result[key] = filterMap(child, full, nil, excludes)
+			} else {
+				result[key] = value
+			}
+			continue
+		}
+		if child, ok := value.(map[string]interface{}); ok {
+			for _, include := range includes {
+				if strings.HasPrefix(include, full+".") || strings.HasPrefix(include, "*") {
+					if filtered := filterMap(child, full, includes, excludes); len(filtered) > 0 {
+						result[key] = filtered
+					}
+					break
+				}
+			}
+		}
+	}
+	// Success
+	return result
+}
+
+func matchAny(patterns []string, field string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, field); ok || strings.HasPrefix(field, pattern+".") {
+			return true
+		}
+	}
+	// Success
+	return false
+}
+
+func toStrings(values []interface{}) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, fmt.Sprint(value))
+	}
+	// Success
+	return result
+}
+
+// highlight wraps query terms found in the requested fields with the
+// configured tags, one fragment per matching value
+func highlight(doc *document, q interface{}, spec map[string]interface{}) map[string][]string {
+	fields, _ := spec["fields"].(map[string]interface{})
+	if len(fields) == 0 {
+		if list, ok := spec["fields"].([]interface{}); ok {
+			fields = make(map[string]interface{})
+			for _, item := range list {
+				if m, ok := item.(map[string]interface{}); ok {
+					for key, value := range m {
+						fields[key] = value
+					}
+				}
+			}
+		}
+	}
+	preTag, postTag := "<em>", "</em>"
+	if tags := toStrings(asSlice(spec["pre_tags"])); len(tags) > 0 {
+		preTag = tags[0]
+	}
+	if tags := toStrings(asSlice(spec["post_tags"])); len(tags) > 0 {
+		postTag = tags[0]
+	}
+	result := make(map[string][]string)
+	for field := range fields {
+		terms := make(map[string]bool)
+		collectTerms(q, field, terms)
+		if len(terms) == 0 {
+			continue
+		}
+		for _, value := range lookup(doc, field) {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if fragment, changed := markTerms(text, terms, preTag, postTag); changed {
+				result[field] = append(result[field], fragment)
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	// Success
+	return result
+}
+
+func collectTerms(q interface{}, field string, terms map[string]bool) {
+	switch v := q.(type) {
+	case []interface{}:
+		for _, item := range v {
+			collectTerms(item, field, terms)
+		}
+	case map[string]interface{}:
+		for kind, value := range v {
+			params, _ := value.(map[string]interface{})
+			switch kind {
+			case "bool":
+				for _, key := range []string{"must", "filter", "should"} {
+					collectTerms(params[key], field, terms)
+				}
+			case "multi_match":
+				for _, token := range tokenize(fmt.Sprint(params["query"])) {
+					terms[token] = true
+				}
+			case "match", "match_phrase", "match_phrase_prefix", "term", "prefix", "search_as_you_type":
+				for key, arg := range params {
+					if key == field || strings.TrimSuffix(key, ".keyword") == field {
+						text := unwrap(arg, "query")
+						if text == nil {
+							text = unwrap(arg, "value")
+						}
+						for _, token := range tokenize(fmt.Sprint(text)) {
+							terms[token] = true
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func markTerms(text string, terms map[string]bool, preTag, postTag string) (string, bool) {
+	var builder strings.Builder
+	changed := false
+	word := make([]rune, 0)
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if terms[strings.ToLower(string(word))] {
+			builder.WriteString(preTag + string(word) + postTag)
+			changed = true
+		} else {
+			builder.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		builder.WriteRune(r)
+	}
+	flush()
+	// Success
+	return builder.String(), changed
+}
@@ -0,0 +1,828 @@
+package elastictest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/h14yhv/golang-lib/adapter/elastic"
+)
+
+const (
+	Version     = "7.10.2"
+	ClusterName = "elastictest"
+)
+
+type (
+	// Server is an in-process stand-in for an Elasticsearch 7 node, serving
+	// the document, search, count, bulk, delete by query and scroll APIs
+	Server struct {
+		*httptest.Server
+		mutex    sync.Mutex
+		indices  map[string]*index
+		scrolls  map[string]*scrollContext
+		sequence int64
+	}
+
+	scrollContext struct {
+		hits   []*hit
+		size   int
+		total  int
+		search map[string]interface{}
+	}
+
+	apiError struct {
+		Status int
+		Type   string
+		Reason string
+		Index  string
+	}
+)
+
+func NewServer() *Server {
+	s := &Server{
+		indices: make(map[string]*index),
+		scrolls: make(map[string]*scrollContext),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	// Success
+	return s
+}
+
+func (s *Server) Config() elastic.Config {
+	// Success
+	return elastic.Config{Address: s.URL}
+}
+
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.indices = make(map[string]*index)
+	s.scrolls = make(map[string]*scrollContext)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	reader := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, badRequest("parse_exception", err.Error()))
+			return
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		writeError(w, badRequest("parse_exception", err.Error()))
+		return
+	}
+	parts := make([]string, 0)
+	for _, part := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	status, result, apiErr := s.route(r, parts, body)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+	writeJSON(w, r, status, result)
+}
+
+func (s *Server) route(r *http.Request, parts []string, body []byte) (int, interface{}, *apiError) {
+	params := r.URL.Query()
+	if len(parts) == 0 {
+		return http.StatusOK, map[string]interface{}{
+			"name":         "fake",
+			"cluster_name": ClusterName,
+			"version":      map[string]interface{}{"number": Version},
+			"tagline":      "You Know, for Search",
+		}, nil
+	}
+	if strings.HasPrefix(parts[0], "_") {
+		switch {
+		case parts[0] == "_search" && len(parts) == 2 && parts[1] == "scroll":
+			return s.scroll(r, body)
+		case parts[0] == "_search":
+			return s.search("", params, body)
+		case parts[0] == "_count":
+			return s.count("", body)
+		case parts[0] == "_bulk":
+			return s.bulk("", body)
+		case parts[0] == "_mget":
+			return s.mget("", body)
+		case parts[0] == "_msearch":
+			return s.msearch("", body)
+		case parts[0] == "_refresh":
+			return http.StatusOK, shards(), nil
+		}
+		return 0, nil, badRequest("invalid_index_name_exception", fmt.Sprintf("unsupported endpoint [%s]", r.URL.Path))
+	}
+	name := parts[0]
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodHead:
+			if _, ok := s.indices[name]; !ok {
+				return http.StatusNotFound, nil, nil
+			}
+			return http.StatusOK, nil, nil
+		case http.MethodPut:
+			return s.createIndex(name, body)
+		case http.MethodDelete:
+			return s.deleteIndex(name)
+		case http.MethodGet:
+			idx, ok := s.indices[name]
+			if !ok {
+				return 0, nil, indexNotFound(name)
+			}
+			return http.StatusOK, map[string]interface{}{name: map[string]interface{}{"mappings": idx.mapping}}, nil
+		}
+	}
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "_search":
+			return s.search(name, params, body)
+		case "_count":
+			return s.count(name, body)
+		case "_delete_by_query":
+			return s.deleteByQuery(name, body)
+		case "_bulk":
+			return s.bulk(name, body)
+		case "_mget":
+			return s.mget(name, body)
+		case "_msearch":
+			return s.msearch(name, body)
+		case "_refresh":
+			return http.StatusOK, shards(), nil
+		case "_doc":
+			if r.Method == http.MethodPost {
+				return s.indexDoc(name, "", params, body)
+			}
+		}
+	}
+	if len(parts) == 3 {
+		id := parts[2]
+		switch parts[1] {
+		case "_doc":
+			switch r.Method {
+			case http.MethodGet:
+				return s.getDoc(name, id)
+			case http.MethodHead:
+				status, _, _ := s.getDoc(name, id)
+				return status, nil, nil
+			case http.MethodPut, http.MethodPost:
+				return s.indexDoc(name, id, params, body)
+			case http.MethodDelete:
+				return s.deleteDoc(name, id, params)
+			}
+		case "_create":
+			params.Set("op_type", "create")
+			return s.indexDoc(name, id, params, body)
+		case "_update":
+			return s.updateDoc(name, id, params, body)
+		}
+	}
+	// Success
+	return 0, nil, badRequest("illegal_argument_exception", fmt.Sprintf("unsupported request [%s %s]", r.Method, r.URL.Path))
+}
+
+func (s *Server) createIndex(name string, body []byte) (int, interface{}, *apiError) {
+	if _, ok := s.indices[name]; ok {
+		return 0, nil, &apiError{
+			Status: http.StatusBadRequest,
+			Type:   "resource_already_exists_exception",
+			Reason: fmt.Sprintf("index [%s] already exists", name),
+			Index:  name,
+		}
+	}
+	settings := make(map[string]interface{})
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &settings); err != nil {
+			return 0, nil, badRequest("parse_exception", err.Error())
+		}
+	}
+	mapping, _ := settings["mappings"].(map[string]interface{})
+	s.indices[name] = newIndex(name, mapping)
+	// Success
+	return http.StatusOK, map[string]interface{}{"acknowledged": true, "shards_acknowledged": true, "index": name}, nil
+}
+
+func (s *Server) deleteIndex(name string) (int, interface{}, *apiError) {
+	indices, err := s.resolve(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, idx := range indices {
+		delete(s.indices, idx.name)
+	}
+	// Success
+	return http.StatusOK, map[string]interface{}{"acknowledged": true}, nil
+}
+
+func (s *Server) getDoc(name, id string) (int, interface{}, *apiError) {
+	idx, ok := s.indices[name]
+	if !ok {
+		return 0, nil, indexNotFound(name)
+	}
+	doc, ok := idx.docs[id]
+	if !ok {
+		return http.StatusNotFound, map[string]interface{}{"_index": name, "_type": "_doc", "_id": id, "found": false}, nil
+	}
+	// Success
+	return http.StatusOK, getResult(doc), nil
+}
+
+func (s *Server) indexDoc(name, id string, params map[string][]string, body []byte) (int, interface{}, *apiError) {
+	cond, err := condition(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, created, err := s.put(s.index(name, true), id, body, cond)
+	if err != nil {
+		return 0, nil, err
+	}
+	if created {
+		return http.StatusCreated, writeResult(doc, "created"), nil
+	}
+	// Success
+	return http.StatusOK, writeResult(doc, "updated"), nil
+}
+
+func (s *Server) updateDoc(name, id string, params map[string][]string, body []byte) (int, interface{}, *apiError) {
+	cond, err := condition(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	request := make(map[string]interface{})
+	if err := json.Unmarshal(body, &request); err != nil {
+		return 0, nil, badRequest("parse_exception", err.Error())
+	}
+	doc, result, err := s.update(s.index(name, true), id, request, cond)
+	if err != nil {
+		return 0, nil, err
+	}
+	status := http.StatusOK
+	if result == "created" {
+		status = http.StatusCreated
+	}
+	// Success
+	return status, writeResult(doc, result), nil
+}
+
+func (s *Server) deleteDoc(name, id string, params map[string][]string) (int, interface{}, *apiError) {
+	idx, ok := s.indices[name]
+	if !ok {
+		return 0, nil, indexNotFound(name)
+	}
+	cond, err := condition(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, err := s.remove(idx, id, cond)
+	if err != nil {
+		return 0, nil, err
+	}
+	if doc == nil {
+		return http.StatusNotFound, map[string]interface{}{"_index": name, "_type": "_doc", "_id": id, "result": "not_found", "_shards": shards()["_shards"]}, nil
+	}
+	// Success
+	return http.StatusOK, writeResult(doc, "deleted"), nil
+}
+
+func (s *Server) find(expr string, request map[string]interface{}) ([]*hit, *apiError) {
+	indices, err := s.resolve(expr)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*hit, 0)
+	for _, idx := range indices {
+		for _, doc := range idx.sorted() {
+			ok, err := match(request["query"], doc)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				hits = append(hits, &hit{doc: doc, score: 1})
+			}
+		}
+	}
+	if minScore, ok := number(request["min_score"]); ok {
+		filtered := hits[:0]
+		for _, h := range hits {
+			if h.score >= minScore {
+				filtered = append(filtered, h)
+			}
+		}
+		hits = filtered
+	}
+	sortHits(hits, parseSorts(request["sort"]))
+	if collapse, ok := request["collapse"].(map[string]interface{}); ok {
+		field := fmt.Sprint(collapse["field"])
+		seen := make(map[string]bool)
+		filtered := hits[:0]
+		for _, h := range hits {
+			key := fmt.Sprint(lookup(h.doc, field))
+			if !seen[key] {
+				seen[key] = true
+				filtered = append(filtered, h)
+			}
+		}
+		hits = filtered
+	}
+	// Success
+	return hits, nil
+}
+
+func (s *Server) search(expr string, params map[string][]string, body []byte) (int, interface{}, *apiError) {
+	request, err := decodeBody(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	result, err := s.searchRequest(expr, params, request)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Success
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) searchRequest(expr string, params map[string][]string, request map[string]interface{}) (map[string]interface{}, *apiError) {
+	hits, err := s.find(expr, request)
+	if err != nil {
+		return nil, err
+	}
+	from, size := 0, 10
+	if value, ok := number(request["from"]); ok {
+		from = int(value)
+	}
+	if value, ok := number(request["size"]); ok {
+		size = int(value)
+	}
+	if value := first(params, "from"); value != "" {
+		from, _ = strconv.Atoi(value)
+	}
+	if value := first(params, "size"); value != "" {
+		size, _ = strconv.Atoi(value)
+	}
+	if first(params, "seq_no_primary_term") == "true" {
+		request["seq_no_primary_term"] = true
+	}
+	total := len(hits)
+	if spec, ok := request["suggest"].(map[string]interface{}); ok {
+		result := searchResult(page(hits, from, size), total, request)
+		result["suggest"] = suggest(hits, spec)
+		return result, nil
+	}
+	if first(params, "scroll") != "" {
+		s.sequence++
+		id := fmt.Sprintf("scroll-%d", s.sequence)
+		page := page(hits, 0, size)
+		s.scrolls[id] = &scrollContext{hits: hits[len(page):], size: size, total: total, search: request}
+		result := searchResult(page, total, request)
+		result["_scroll_id"] = id
+		return result, nil
+	}
+	// Success
+	return searchResult(page(hits, from, size), total, request), nil
+}
+
+func (s *Server) scroll(r *http.Request, body []byte) (int, interface{}, *apiError) {
+	request, err := decodeBody(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	ids := toStrings(asSlice(request["scroll_id"]))
+	if value := first(r.URL.Query(), "scroll_id"); value != "" {
+		ids = append(ids, value)
+	}
+	if r.Method == http.MethodDelete {
+		freed := 0
+		for _, id := range ids {
+			if id == "_all" {
+				freed += len(s.scrolls)
+				s.scrolls = make(map[string]*scrollContext)
+				continue
+			}
+			if _, ok := s.scrolls[id]; ok {
+				delete(s.scrolls, id)
+				freed++
+			}
+		}
+		return http.StatusOK, map[string]interface{}{"succeeded": true, "num_freed": freed}, nil
+	}
+	if len(ids) == 0 {
+		return 0, nil, badRequest("action_request_validation_exception", "scrollId is missing")
+	}
+	ctx, ok := s.scrolls[ids[0]]
+	if !ok {
+		return 0, nil, &apiError{Status: http.StatusNotFound, Type: "search_context_missing_exception", Reason: fmt.Sprintf("No search context found for id [%s]", ids[0])}
+	}
+	next := page(ctx.hits, 0, ctx.size)
+	ctx.hits = ctx.hits[len(next):]
+	result := searchResult(next, ctx.total, ctx.search)
+	result["_scroll_id"] = ids[0]
+	// Success
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) count(expr string, body []byte) (int, interface{}, *apiError) {
+	request, err := decodeBody(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	hits, err := s.find(expr, map[string]interface{}{"query": request["query"]})
+	if err != nil {
+		return 0, nil, err
+	}
+	result := shards()
+	result["count"] = len(hits)
+	// Success
+	return http.StatusOK, result, nil
+}
+
+func (s *Server) deleteByQuery(expr string, body []byte) (int, interface{}, *apiError) {
+	request, err := decodeBody(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	hits, err := s.find(expr, map[string]interface{}{"query": request["query"]})
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, h := range hits {
+		if _, err := s.remove(s.indices[h.doc.index], h.doc.id, writeCondition{}); err != nil {
+			return 0, nil, err
+		}
+	}
+	// Success
+	return http.StatusOK, map[string]interface{}{
+		"took":              1,
+		"timed_out":         false,
+		"total":             len(hits),
+		"deleted":           len(hits),
+		"batches":           1,
+		"version_conflicts": 0,
+		"noops":             0,
+		"failures":          []interface{}{},
+	}, nil
+}
+
+func (s *Server) bulk(defaultIndex string, body []byte) (int, interface{}, *apiError) {
+	lines := ndjson(body)
+	items := make([]interface{}, 0)
+	failed := false
+	for i := 0; i < len(lines); i++ {
+		action := make(map[string]map[string]interface{})
+		if err := json.Unmarshal(lines[i], &action); err != nil || len(action) != 1 {
+			return 0, nil, badRequest("illegal_argument_exception", "malformed action/metadata line")
+		}
+		for op, meta := range action {
+			name := defaultIndex
+			if value, ok := meta["_index"].(string); ok && value != "" {
+				name = value
+			}
+			id, _ := meta["_id"].(string)
+			cond := writeCondition{create: op == "create"}
+			if value, ok := number(meta["if_seq_no"]); ok {
+				seqNo := int64(value)
+				cond.ifSeqNo = &seqNo
+			}
+			if value, ok := number(meta["if_primary_term"]); ok {
+				primaryTerm := int64(value)
+				cond.ifPrimaryTerm = &primaryTerm
+			}
+			var (
+				doc    *document
+				result string
+				status int
+				apiErr *apiError
+			)
+			switch op {
+			case "index", "create":
+				i++
+				if i >= len(lines) {
+					return 0, nil, badRequest("illegal_argument_exception", "missing source line")
+				}
+				var created bool
+				doc, created, apiErr = s.put(s.index(name, true), id, lines[i], cond)
+				result, status = "updated", http.StatusOK
+				if created {
+					result, status = "created", http.StatusCreated
+				}
+			case "update":
+				i++
+				if i >= len(lines) {
+					return 0, nil, badRequest("illegal_argument_exception", "missing source line")
+				}
+				request := make(map[string]interface{})
+				if err := json.Unmarshal(lines[i], &request); err != nil {
+					apiErr = badRequest("parse_exception", err.Error())
+					break
+				}
+				doc, result, apiErr = s.update(s.index(name, true), id, request, cond)
+				status = http.StatusOK
+				if result == "created" {
+					status = http.StatusCreated
+				}
+			case "delete":
+				idx, ok := s.indices[name]
+				if !ok {
+					apiErr = indexNotFound(name)
+					break
+				}
+				doc, apiErr = s.remove(idx, id, cond)
+				result, status = "deleted", http.StatusOK
+				if apiErr == nil && doc == nil {
+					result, status = "not_found", http.StatusNotFound
+				}
+			default:
+				return 0, nil, badRequest("illegal_argument_exception", fmt.Sprintf("unknown bulk action [%s]", op))
+			}
+			item := map[string]interface{}{"_index": name, "_type": "_doc", "_id": id}
+			if apiErr != nil {
+				failed = true
+				item["status"] = apiErr.Status
+				item["error"] = apiErr.details()
+			} else {
+				item["status"] = status
+				item["result"] = result
+				if doc != nil {
+					item["_id"] = doc.id
+					item["_version"] = doc.version
+					item["_seq_no"] = doc.seqNo
+					item["_primary_term"] = doc.primaryTerm
+				}
+			}
+			items = append(items, map[string]interface{}{op: item})
+		}
+	}
+	// Success
+	return http.StatusOK, map[string]interface{}{"took": 1, "errors": failed, "items": items}, nil
+}
+
+func (s *Server) mget(defaultIndex string, body []byte) (int, interface{}, *apiError) {
+	request, err := decodeBody(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	type item struct {
+		index string
+		id    string
+	}
+	items := make([]item, 0)
+	for _, value := range asSlice(request["docs"]) {
+		spec, _ := value.(map[string]interface{})
+		name := defaultIndex
+		if value, ok := spec["_index"].(string); ok && value != "" {
+			name = value
+		}
+		items = append(items, item{index: name, id: fmt.Sprint(spec["_id"])})
+	}
+	for _, id := range asSlice(request["ids"]) {
+		items = append(items, item{index: defaultIndex, id: fmt.Sprint(id)})
+	}
+	docs := make([]interface{}, 0, len(items))
+	for _, it := range items {
+		idx, ok := s.indices[it.index]
+		if !ok {
+			docs = append(docs, map[string]interface{}{"_index": it.index, "_type": "_doc", "_id": it.id, "error": indexNotFound(it.index).details()})
+			continue
+		}
+		doc, ok := idx.docs[it.id]
+		if !ok {
+			docs = append(docs, map[string]interface{}{"_index": it.index, "_type": "_doc", "_id": it.id, "found": false})
+			continue
+		}
+		docs = append(docs, getResult(doc))
+	}
+	// Success
+	return http.StatusOK, map[string]interface{}{"docs": docs}, nil
+}
+
+func (s *Server) msearch(defaultIndex string, body []byte) (int, interface{}, *apiError) {
+	lines := ndjson(body)
+	responses := make([]interface{}, 0)
+	for i := 0; i+1 < len(lines); i += 2 {
+		header, err := decodeBody(lines[i])
+		if err != nil {
+			return 0, nil, err
+		}
+		request, err := decodeBody(lines[i+1])
+		if err != nil {
+			return 0, nil, err
+		}
+		expr := strings.Join(toStrings(asSlice(header["index"])), ",")
+		if expr == "" {
+			expr = defaultIndex
+		}
+		result, apiErr := s.searchRequest(expr, nil, request)
+		if apiErr != nil {
+			responses = append(responses, map[string]interface{}{"error": apiErr.details(), "status": apiErr.Status})
+			continue
+		}
+		result["status"] = http.StatusOK
+		responses = append(responses, result)
+	}
+	// Success
+	return http.StatusOK, map[string]interface{}{"took": 1, "responses": responses}, nil
+}
+
+func searchResult(hits []*hit, total int, request map[string]interface{}) map[string]interface{} {
+	seqNo, _ := request["seq_no_primary_term"].(bool)
+	spec, _ := request["highlight"].(map[string]interface{})
+	_, sorted := request["sort"]
+	items := make([]interface{}, 0, len(hits))
+	for _, h := range hits {
+		item := map[string]interface{}{
+			"_index": h.doc.index,
+			"_type":  "_doc",
+			"_id":    h.doc.id,
+			"_score": h.score,
+		}
+		if source := filterSource(h.doc, request["_source"]); source != nil {
+			item["_source"] = source
+		}
+		if seqNo {
+			item["_seq_no"] = h.doc.seqNo
+			item["_primary_term"] = h.doc.primaryTerm
+		}
+		if sorted {
+			item["sort"] = h.sort
+		}
+		if spec != nil {
+			if fragments := highlight(h.doc, request["query"], spec); fragments != nil {
+				item["highlight"] = fragments
+			}
+		}
+		items = append(items, item)
+	}
+	result := shards()
+	result["took"] = 1
+	result["timed_out"] = false
+	result["hits"] = map[string]interface{}{
+		"total":     map[string]interface{}{"value": total, "relation": "eq"},
+		"max_score": 1,
+		"hits":      items,
+	}
+	// Success
+	return result
+}
+
+func getResult(doc *document) map[string]interface{} {
+	// Success
+	return map[string]interface{}{
+		"_index":        doc.index,
+		"_type":         "_doc",
+		"_id":           doc.id,
+		"_version":      doc.version,
+		"_seq_no":       doc.seqNo,
+		"_primary_term": doc.primaryTerm,
+		"found":         true,
+		"_source":       doc.raw,
+	}
+}
+
+func writeResult(doc *document, result string) map[string]interface{} {
+	response := shards()
+	response["_index"] = doc.index
+	response["_type"] = "_doc"
+	response["_id"] = doc.id
+	response["_version"] = doc.version
+	response["_seq_no"] = doc.seqNo
+	response["_primary_term"] = doc.primaryTerm
+	response["result"] = result
+	// Success
+	return response
+}
+
+func shards() map[string]interface{} {
+	// Success
+	return map[string]interface{}{"_shards": map[string]interface{}{"total": 1, "successful": 1, "skipped": 0, "failed": 0}}
+}
+
+func page(hits []*hit, from, size int) []*hit {
+	if from >= len(hits) || size <= 0 {
+		return []*hit{}
+	}
+	end := from + size
+	if end > len(hits) {
+		end = len(hits)
+	}
+	// Success
+	return hits[from:end]
+}
+
+func condition(params map[string][]string) (writeCondition, *apiError) {
+	cond := writeCondition{create: first(params, "op_type") == "create"}
+	if value := first(params, "if_seq_no"); value != "" {
+		seqNo, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return cond, badRequest("illegal_argument_exception", "invalid if_seq_no")
+		}
+		cond.ifSeqNo = &seqNo
+	}
+	if value := first(params, "if_primary_term"); value != "" {
+		primaryTerm, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return cond, badRequest("illegal_argument_exception", "invalid if_primary_term")
+		}
+		cond.ifPrimaryTerm = &primaryTerm
+	}
+	// Success
+	return cond, nil
+}
+
+func first(params map[string][]string, key string) string {
+	if values := params[key]; len(values) > 0 {
+		return values[0]
+	}
+	// Success
+	return ""
+}
+
+func decodeBody(body []byte) (map[string]interface{}, *apiError) {
+	request := make(map[string]interface{})
+	if len(bytes.TrimSpace(body)) == 0 {
+		return request, nil
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, badRequest("parse_exception", err.Error())
+	}
+	// Success
+	return request, nil
+}
+
+func ndjson(body []byte) [][]byte {
+	lines := make([][]byte, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) > 0 {
+			lines = append(lines, append([]byte{}, line...))
+		}
+	}
+	// Success
+	return lines
+}
+
+func badRequest(kind, reason string) *apiError {
+	// Success
+	return &apiError{Status: http.StatusBadRequest, Type: kind, Reason: reason}
+}
+
+func indexNotFound(name string) *apiError {
+	// Success
+	return &apiError{
+		Status: http.StatusNotFound,
+		Type:   "index_not_found_exception",
+		Reason: fmt.Sprintf("no such index [%s]", name),
+		Index:  name,
+	}
+}
+
+func (e *apiError) details() map[string]interface{} {
+	details := map[string]interface{}{"type": e.Type, "reason": e.Reason}
+	if e.Index != "" {
+		details["index"] = e.Index
+	}
+	// Success
+	return details
+}
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	details := e.details()
+	details["root_cause"] = []interface{}{e.details()}
+	bts, _ := json.Marshal(map[string]interface{}{"error": details, "status": e.Status})
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(e.Status)
+	_, _ = w.Write(bts)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, result interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if result == nil || r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	bts, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, &apiError{Status: http.StatusInternalServerError, Type: "exception", Reason: err.Error()})
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(bts)
+}
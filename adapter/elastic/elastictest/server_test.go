@@ -0,0 +1,109 @@
+package elastictest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/h14yhv/golang-lib/adapter/elastic"
+)
+
+const testIndex = "items"
+
+type item struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newDatabase(t *testing.T) elastic.Database {
+	t.Helper()
+	server := NewServer()
+	t.Cleanup(server.Close)
+	db, err := elastic.NewService(server.Config())
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	// Success
+	return db
+}
+
+func TestInsertGetDelete(t *testing.T) {
+	db := newDatabase(t)
+	if err := db.InsertOne(testIndex, "", elastic.Doc{"id": "1", "name": "one", "count": 1}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	var result item
+	if err := db.Get(testIndex, "", "1", &result); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if result.Name != "one" || result.Count != 1 {
+		t.Fatalf("get returned %+v", result)
+	}
+	if err := db.DeleteByID(testIndex, "", "1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := db.Get(testIndex, "", "1", &result); err != elastic.ErrNotFound {
+		t.Fatalf("get after delete returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestVersionedWrites(t *testing.T) {
+	db := newDatabase(t)
+	version, err := db.InsertOneWithOptions(testIndex, "", elastic.Doc{"id": "1", "name": "one"}, elastic.WriteOptions{})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err = db.UpdateByIDWithOptions(testIndex, "", "1", elastic.M{"name": "two"}, elastic.WriteOptions{Version: version}); err != nil {
+		t.Fatalf("update with current version: %v", err)
+	}
+	// The version moved on, reusing it must conflict
+	if _, err = db.UpdateByIDWithOptions(testIndex, "", "1", elastic.M{"name": "three"}, elastic.WriteOptions{Version: version}); !errors.Is(err, elastic.ErrConflict) {
+		t.Fatalf("stale update returned %v, want ErrConflict", err)
+	}
+	if err = db.DeleteByIDWithOptions(testIndex, "", "1", elastic.WriteOptions{Version: version}); !errors.Is(err, elastic.ErrConflict) {
+		t.Fatalf("stale delete returned %v, want ErrConflict", err)
+	}
+}
+
+func TestUpsertChecksVersion(t *testing.T) {
+	db := newDatabase(t)
+	if err := db.InsertOne(testIndex, "", elastic.Doc{"id": "0", "name": "zero"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	options := elastic.WriteOptions{Upsert: true, Version: &elastic.Version{SeqNo: 0, PrimaryTerm: 1}}
+	if _, err := db.UpdateByIDWithOptions(testIndex, "", "missing", elastic.M{"name": "new"}, options); !errors.Is(err, elastic.ErrConflict) {
+		t.Fatalf("versioned upsert of a missing document returned %v, want ErrConflict", err)
+	}
+	if exists, err := db.Exists(testIndex, "", "missing"); err != nil || exists {
+		t.Fatalf("conflicting upsert created the document (exists %v, err %v)", exists, err)
+	}
+	options.Version = nil
+	if _, err := db.UpdateByIDWithOptions(testIndex, "", "missing", elastic.M{"name": "new"}, options); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+}
+
+func TestFindPaging(t *testing.T) {
+	db := newDatabase(t)
+	docs := []elastic.Document{
+		elastic.Doc{"id": "1", "name": "apple", "count": 3},
+		elastic.Doc{"id": "2", "name": "banana", "count": 1},
+		elastic.Doc{"id": "3", "name": "apple pie", "count": 2},
+	}
+	if err := db.InsertMany(testIndex, "", docs); err != nil {
+		t.Fatalf("insert many: %v", err)
+	}
+	query := elastic.Query{"match": elastic.M{"name": "apple"}}
+	var results []item
+	total, err := db.FindPaging(testIndex, "", query, []string{"+count"}, 0, 10, &results)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if total != 2 || len(results) != 2 || results[0].ID != "3" || results[1].ID != "1" {
+		t.Fatalf("find returned %d %+v", total, results)
+	}
+	count, err := db.Count(testIndex, "", query)
+	if err != nil || count != 2 {
+		t.Fatalf("count returned %d, %v", count, err)
+	}
+}
@@ -0,0 +1,268 @@
+package elastictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+type (
+	index struct {
+		name    string
+		mapping map[string]interface{}
+		docs    map[string]*document
+		seqNo   int64
+	}
+
+	document struct {
+		index       string
+		id          string
+		raw         json.RawMessage
+		source      map[string]interface{}
+		version     int64
+		seqNo       int64
+		primaryTerm int64
+		order       int64
+	}
+
+	writeCondition struct {
+		ifSeqNo       *int64
+		ifPrimaryTerm *int64
+		create        bool
+	}
+)
+
+func newIndex(name string, mapping map[string]interface{}) *index {
+	// Success
+	return &index{name: name, mapping: mapping, docs: make(map[string]*document), seqNo: -1}
+}
+
+func (s *Server) index(name string, create bool) *index {
+	idx, ok := s.indices[name]
+	if !ok && create {
+		idx = newIndex(name, nil)
+		s.indices[name] = idx
+	}
+	// Success
+	return idx
+}
+
+// resolve expands a comma separated index expression with wildcards into
+// existing indices, failing on missing concrete names like Elasticsearch
+func (s *Server) resolve(expr string) ([]*index, *apiError) {
+	if expr == "" || expr == "_all" {
+		expr = "*"
+	}
+	seen := make(map[string]bool)
+	result := make([]*index, 0)
+	for _, name := range strings.Split(expr, ",") {
+		if strings.ContainsAny(name, "*?") {
+			for key, idx := range s.indices {
+				if ok, _ := path.Match(name, key); ok && !seen[key] {
+					seen[key] = true
+					result = append(result, idx)
+				}
+			}
+			continue
+		}
+		idx, ok := s.indices[name]
+		if !ok {
+			return nil, indexNotFound(name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, idx)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	// Success
+	return result, nil
+}
+
+func (idx *index) check(doc *document, cond writeCondition) *apiError {
+	if cond.create && doc != nil {
+		return &apiError{
+			Status: http.StatusConflict,
+			Type:   "version_conflict_engine_exception",
+			Reason: fmt.Sprintf("[%s]: version conflict, document already exists (current version [%d])", doc.id, doc.version),
+			Index:  idx.name,
+		}
+	}
+	if cond.ifSeqNo == nil && cond.ifPrimaryTerm == nil {
+		return nil
+	}
+	if doc == nil {
+		return &apiError{
+			Status: http.StatusConflict,
+			Type:   "version_conflict_engine_exception",
+			Reason: "version conflict, document does not exist",
+			Index:  idx.name,
+		}
+	}
+	if (cond.ifSeqNo != nil && *cond.ifSeqNo != doc.seqNo) || (cond.ifPrimaryTerm != nil && *cond.ifPrimaryTerm != doc.primaryTerm) {
+		return &apiError{
+			Status: http.StatusConflict,
+			Type:   "version_conflict_engine_exception",
+			Reason: fmt.Sprintf("[%s]: version conflict, current document has seqNo [%d] and primary term [%d]", doc.id, doc.seqNo, doc.primaryTerm),
+			Index:  idx.name,
+		}
+	}
+	// Success
+	return nil
+}
+
+func (s *Server) put(idx *index, id string, raw []byte, cond writeCondition) (*document, bool, *apiError) {
+	source := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return nil, false, badRequest("mapper_parsing_exception", "failed to parse: "+err.Error())
+	}
+	current := idx.docs[id]
+	if err := idx.check(current, cond); err != nil {
+		return nil, false, err
+	}
+	if id == "" {
+		s.sequence++
+		id = fmt.Sprintf("fake-%d", s.sequence)
+	}
+	idx.seqNo++
+	s.sequence++
+	doc := &document{
+		index:       idx.name,
+		id:          id,
+		raw:         append(json.RawMessage{}, raw...),
+		source:      source,
+		version:     1,
+		seqNo:       idx.seqNo,
+		primaryTerm: 1,
+		order:       s.sequence,
+	}
+	if current != nil {
+		doc.version = current.version + 1
+		doc.order = current.order
+	}
+	idx.docs[id] = doc
+	// Success
+	return doc, current == nil, nil
+}
+
+func (s *Server) remove(idx *index, id string, cond writeCondition) (*document, *apiError) {
+	current := idx.docs[id]
+	if err := idx.check(current, cond); err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, nil
+	}
+	delete(idx.docs, id)
+	idx.seqNo++
+	deleted := *current
+	deleted.version++
+	deleted.seqNo = idx.seqNo
+	// Success
+	return &deleted, nil
+}
+
+func (s *Server) update(idx *index, id string, body map[string]interface{}, cond writeCondition) (*document, string, *apiError) {
+	current := idx.docs[id]
+	partial, _ := body["doc"].(map[string]interface{})
+	upsert, _ := body["upsert"].(map[string]interface{})
+	docAsUpsert, _ := body["doc_as_upsert"].(bool)
+	if _, ok := body["script"]; ok {
+		return nil, "", badRequest("illegal_argument_exception", "scripted updates are not supported")
+	}
+	// Preconditions hold for upserts too, a missing document conflicts
+	if err := idx.check(current, cond); err != nil {
+		return nil, "", err
+	}
+	var merged map[string]interface{}
+	switch {
+	case current != nil:
+		merged = deepCopy(current.source).(map[string]interface{})
+		mergeInto(merged, partial)
+		if equalJSON(merged, current.source) {
+			return current, "noop", nil
+		}
+	case upsert != nil:
+		merged = upsert
+	case docAsUpsert && partial != nil:
+		merged = partial
+	default:
+		return nil, "", &apiError{
+			Status: http.StatusNotFound,
+			Type:   "document_missing_exception",
+			Reason: fmt.Sprintf("[_doc][%s]: document missing", id),
+			Index:  idx.name,
+		}
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, "", badRequest("illegal_argument_exception", err.Error())
+	}
+	doc, created, apiErr := s.put(idx, id, raw, writeCondition{})
+	if apiErr != nil {
+		return nil, "", apiErr
+	}
+	result := "updated"
+	if created {
+		result = "created"
+	}
+	// Success
+	return doc, result, nil
+}
+
+func (idx *index) sorted() []*document {
+	docs := make([]*document, 0, len(idx.docs))
+	for _, doc := range idx.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].order < docs[j].order })
+	// Success
+	return docs
+}
+
+func mergeInto(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if child, ok := value.(map[string]interface{}); ok {
+			if current, ok := dst[key].(map[string]interface{}); ok {
+				mergeInto(current, child)
+				continue
+			}
+		}
+		dst[key] = deepCopy(value)
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = deepCopy(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+		return result
+	}
+	// Success
+	return value
+}
+
+func equalJSON(a, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	// Success
+	return string(left) == string(right)
+}
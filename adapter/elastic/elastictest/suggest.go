@@ -0,0 +1,214 @@
+package elastictest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type option struct {
+	text  string
+	score float64
+	freq  int
+	doc   *document
+}
+
+// suggest evaluates completion, term and phrase suggesters against the
+// documents matched by the search query
+func suggest(hits []*hit, spec map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	global, _ := spec["text"].(string)
+	for name, value := range spec {
+		body, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		text, _ := body["text"].(string)
+		if text == "" {
+			text = global
+		}
+		switch {
+		case body["completion"] != nil:
+			params, _ := body["completion"].(map[string]interface{})
+			prefix, _ := body["prefix"].(string)
+			fuzzy := params["fuzzy"] != nil
+			options := completion(hits, fmt.Sprint(params["field"]), prefix, fuzzy, size(params))
+			result[name] = []interface{}{entry(prefix, 0, options, true)}
+		case body["term"] != nil:
+			params, _ := body["term"].(map[string]interface{})
+			entries := make([]interface{}, 0)
+			offset := 0
+			for _, token := range tokenize(text) {
+				idx := strings.Index(strings.ToLower(text[offset:]), token)
+				if idx >= 0 {
+					offset += idx
+				}
+				entries = append(entries, entry(token, offset, corrections(hits, fmt.Sprint(params["field"]), token, size(params)), false))
+				offset += len(token)
+			}
+			result[name] = entries
+		case body["phrase"] != nil:
+			params, _ := body["phrase"].(map[string]interface{})
+			result[name] = []interface{}{entry(text, 0, phrase(hits, fmt.Sprint(params["field"]), text), false)}
+		}
+	}
+	// Success
+	return result
+}
+
+func entry(text string, offset int, options []option, completion bool) map[string]interface{} {
+	items := make([]interface{}, 0, len(options))
+	for _, o := range options {
+		item := map[string]interface{}{"text": o.text}
+		if completion {
+			item["_score"] = o.score
+			item["_index"] = o.doc.index
+			item["_type"] = "_doc"
+			item["_id"] = o.doc.id
+			item["_source"] = o.doc.raw
+		} else {
+			item["score"] = o.score
+			item["freq"] = o.freq
+		}
+		items = append(items, item)
+	}
+	// Success
+	return map[string]interface{}{"text": text, "offset": offset, "length": len(text), "options": items}
+}
+
+func completion(hits []*hit, field, prefix string, fuzzy bool, limit int) []option {
+	prefix = strings.ToLower(prefix)
+	seen := make(map[string]bool)
+	options := make([]option, 0)
+	for _, h := range hits {
+		for _, value := range lookup(h.doc, field) {
+			inputs := []interface{}{value}
+			weight := 1.0
+			if m, ok := value.(map[string]interface{}); ok {
+				inputs = asSlice(m["input"])
+				if w, ok := number(m["weight"]); ok {
+					weight = w
+				}
+			}
+			for _, input := range inputs {
+				text := fmt.Sprint(input)
+				lower := strings.ToLower(text)
+				matched := strings.HasPrefix(lower, prefix)
+				if !matched && fuzzy && len(lower) >= len(prefix) {
+					matched = distance(lower[:len(prefix)], prefix) <= 1
+				}
+				if matched && !seen[text] {
+					seen[text] = true
+					options = append(options, option{text: text, score: weight, doc: h.doc})
+				}
+			}
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].score != options[j].score {
+			return options[i].score > options[j].score
+		}
+		return options[i].text < options[j].text
+	})
+	if len(options) > limit {
+		options = options[:limit]
+	}
+	// Success
+	return options
+}
+
+func corrections(hits []*hit, field, token string, limit int) []option {
+	freq := make(map[string]int)
+	for _, h := range hits {
+		for _, value := range lookup(h.doc, field) {
+			for _, candidate := range tokenize(fmt.Sprint(value)) {
+				freq[candidate]++
+			}
+		}
+	}
+	if freq[token] > 0 {
+		return []option{}
+	}
+	options := make([]option, 0)
+	for candidate, count := range freq {
+		d := distance(candidate, token)
+		if d == 0 || d > 2 {
+			continue
+		}
+		options = append(options, option{text: candidate, score: 1 - float64(d)/float64(len(token)+1), freq: count})
+	}
+	sort.Slice(options, func(i, j int) bool {
+		if options[i].score != options[j].score {
+			return options[i].score > options[j].score
+		}
+		if options[i].freq != options[j].freq {
+			return options[i].freq > options[j].freq
+		}
+		return options[i].text < options[j].text
+	})
+	if len(options) > limit {
+		options = options[:limit]
+	}
+	// Success
+	return options
+}
+
+func phrase(hits []*hit, field, text string) []option {
+	tokens := tokenize(text)
+	changed := false
+	score := 1.0
+	for i, token := range tokens {
+		if best := corrections(hits, field, token, 1); len(best) > 0 {
+			tokens[i] = best[0].text
+			score *= best[0].score
+			changed = true
+		}
+	}
+	if !changed {
+		return []option{}
+	}
+	// Success
+	return []option{{text: strings.Join(tokens, " "), score: score}}
+}
+
+func size(params map[string]interface{}) int {
+	if value, ok := number(params["size"]); ok && value > 0 {
+		return int(value)
+	}
+	// Success
+	return 5
+}
+
+// distance is the Levenshtein edit distance between two strings
+func distance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	prev := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		curr := make([]int, len(y)+1)
+		curr[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			curr[j] = minimum(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	// Success
+	return prev[len(y)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	// Success
+	return result
+}
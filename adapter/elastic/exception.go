@@ -1,6 +1,165 @@
 package elastic
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	es "github.com/olivere/elastic/v7"
+)
+
 const (
-	NotFoundError     = "not found"
-	ResultNotAPointer = "result not a pointer"
+	NotFoundError        = "not found"
+	ConflictError        = "conflict"
+	BadRequestError      = "bad request"
+	IndexNotFoundError   = "index not found"
+	TimeoutError         = "timeout"
+	ResultNotAPointer    = "result not a pointer"
+	InvalidCACertificate = "invalid ca certificate"
+	ResponseMismatch     = "response count mismatch"
+
+	typeIndexNotFound = "index_not_found_exception"
+)
+
+var (
+	ErrNotFound          = errors.New(NotFoundError)
+	ErrConflict          = errors.New(ConflictError)
+	ErrBadRequest        = errors.New(BadRequestError)
+	ErrIndexNotFound     = errors.New(IndexNotFoundError)
+	ErrTimeout           = errors.New(TimeoutError)
+	ErrResultNotAPointer = errors.New(ResultNotAPointer)
+	ErrResponseMismatch  = errors.New(ResponseMismatch)
+)
+
+type (
+	Error struct {
+		Status int
+		Type   string
+		Reason string
+		Index  string
+		ID     string
+		Cause  error
+	}
+
+	BulkError struct {
+		Total int
+		Items []*Error
+	}
 )
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("elastic: status %d", e.Status)
+	if e.Type != "" {
+		msg = fmt.Sprintf("%s, type %s", msg, e.Type)
+	}
+	if e.Index != "" {
+		msg = fmt.Sprintf("%s, index %s", msg, e.Index)
+	}
+	if e.ID != "" {
+		msg = fmt.Sprintf("%s, id %s", msg, e.ID)
+	}
+	if e.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Reason)
+	} else if e.Status != 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.ToLower(http.StatusText(e.Status)))
+	} else if e.Cause != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Cause)
+	}
+	// Success
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	// Success
+	return e.Cause
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound || e.Type == typeIndexNotFound
+	case ErrIndexNotFound:
+		return e.Type == typeIndexNotFound
+	case ErrConflict:
+		return e.Status == http.StatusConflict
+	case ErrBadRequest:
+		return e.Status == http.StatusBadRequest
+	case ErrTimeout:
+		return e.Status == http.StatusRequestTimeout || e.Status == http.StatusGatewayTimeout
+	}
+	// Success
+	return false
+}
+
+func (e *BulkError) Error() string {
+	msg := fmt.Sprintf("elastic: bulk failed for %d of %d items", len(e.Items), e.Total)
+	if len(e.Items) > 0 {
+		msg = fmt.Sprintf("%s, first: %v", msg, e.Items[0])
+	}
+	// Success
+	return msg
+}
+
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Status: http.StatusRequestTimeout, Reason: TimeoutError, Cause: err}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &Error{Status: http.StatusRequestTimeout, Reason: TimeoutError, Cause: err}
+	}
+	var esErr *es.Error
+	if errors.As(err, &esErr) {
+		result := &Error{Status: esErr.Status, Cause: err}
+		if esErr.Details != nil {
+			result.Type = esErr.Details.Type
+			result.Reason = esErr.Details.Reason
+			result.Index = esErr.Details.Index
+		}
+		return result
+	}
+	// Success
+	return err
+}
+
+func itemError(status int, details *es.ErrorDetails, index, id string) *Error {
+	result := &Error{Status: status, Index: index, ID: id}
+	if details != nil {
+		result.Type = details.Type
+		result.Reason = details.Reason
+		if result.Index == "" {
+			result.Index = details.Index
+		}
+	}
+	// Success
+	return result
+}
+
+func bulkError(res *es.BulkResponse) error {
+	if res == nil || !res.Errors {
+		return nil
+	}
+	result := &BulkError{Total: len(res.Items)}
+	for _, item := range res.Items {
+		for _, action := range item {
+			if action == nil || action.Error == nil {
+				continue
+			}
+			result.Items = append(result.Items, itemError(action.Status, action.Error, action.Index, action.Id))
+		}
+	}
+	if len(result.Items) == 0 {
+		return nil
+	}
+	// Success
+	return result
+}
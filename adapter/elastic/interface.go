@@ -1,18 +1,32 @@
 package elastic
 
 type Database interface {
+	CreateIndex(database, collection string, mapping M) error
 	Get(database, collection, id string, result interface{}) error
+	GetWithVersion(database, collection, id string, result interface{}) (*Version, error)
 	Exists(database, collection, id string) (bool, error)
 	Count(database, collection string, query Query) (int64, error)
 	FindOne(database, collection string, query Query, sorts []string, result interface{}) error
+	FindOneWithOptions(database, collection string, query Query, sorts []string, opts SearchOptions, result interface{}) (*Hit, error)
 	FindPaging(database, collection string, query Query, sorts []string, page, size int, results interface{}) (int64, error)
+	FindPagingWithOptions(database, collection string, query Query, sorts []string, page, size int, opts SearchOptions, results interface{}) (*SearchResult, error)
 	FindOffset(database, collection string, query Query, sorts []string, offset, size int, results interface{}) (int64, error)
+	FindOffsetWithOptions(database, collection string, query Query, sorts []string, offset, size int, opts SearchOptions, results interface{}) (*SearchResult, error)
+	MultiGet(database, collection string, ids []string, results interface{}) ([]GetResult, error)
+	MultiSearch(requests []SearchRequest) ([]SearchResponse, error)
+	Complete(database, collection, field, prefix string, size int, fuzzy bool) ([]Suggestion, error)
+	SuggestTerm(database, collection, field, text string, size int) ([]Suggestion, error)
+	SuggestPhrase(database, collection, field, text string, size int) ([]Suggestion, error)
+	SearchAsYouType(database, collection, field, text string, size int, results interface{}) (int64, error)
 	FindScroll(database, collection string, query Query, sorts []string, size int, scrollID, keepAlive string, results interface{}) (string, int64, error)
 	InsertOne(database, collection string, doc Document) error
+	InsertOneWithOptions(database, collection string, doc Document, opts WriteOptions) (*Version, error)
 	InsertMany(database, collection string, docs []Document) error
 	UpdateByID(database, collection, id string, update interface{}, upsert bool) error
+	UpdateByIDWithOptions(database, collection, id string, update interface{}, opts WriteOptions) (*Version, error)
 	UpdateOne(database, collection string, query Query, update interface{}, upsert bool) error
 	UpdateMany(database, collection string, query Query, update interface{}, upsert bool) error
 	DeleteByID(database, collection, id string) error
+	DeleteByIDWithOptions(database, collection, id string, opts WriteOptions) error
 	DeleteMany(database, collection string, query Query) error
 }
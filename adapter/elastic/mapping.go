@@ -0,0 +1,51 @@
+package elastic
+
+func Mapping(properties M) M {
+	// Success
+	return M{"mappings": M{"properties": properties}}
+}
+
+func KeywordField() M {
+	// Success
+	return M{"type": "keyword"}
+}
+
+func TextField(analyzer string) M {
+	field := M{"type": "text"}
+	if analyzer != "" {
+		field["analyzer"] = analyzer
+	}
+	// Success
+	return field
+}
+
+func CompletionField(analyzer string) M {
+	field := M{"type": "completion"}
+	if analyzer != "" {
+		field["analyzer"] = analyzer
+	}
+	// Success
+	return field
+}
+
+func SearchAsYouTypeField(analyzer string) M {
+	field := M{"type": "search_as_you_type"}
+	if analyzer != "" {
+		field["analyzer"] = analyzer
+	}
+	// Success
+	return field
+}
+
+// WithCompletion adds a completion sub field, e.g. "name.suggest", to an
+// existing text or keyword field declaration
+func WithCompletion(field M, name string) M {
+	fields, ok := field["fields"].(M)
+	if !ok {
+		fields = M{}
+	}
+	fields[name] = CompletionField("")
+	field["fields"] = fields
+	// Success
+	return field
+}
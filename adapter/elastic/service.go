@@ -13,7 +13,7 @@ type ModelV7 struct {
 }
 
 func NewService(conf Config) (Database, error) {
-	con, err := newElastic(conf.String())
+	con, err := newElastic(conf)
 	if err != nil {
 		return nil, err
 	}
@@ -21,20 +21,39 @@ func NewService(conf Config) (Database, error) {
 	return &ModelV7{model: con}, nil
 }
 
-func (con *ModelV7) Get(database, _, id string, result interface{}) error {
-	res, err := con.model.Get(database, id)
+func (con *ModelV7) CreateIndex(database, _ string, mapping M) error {
+	_, err := con.model.CreateIndex(database, mapping)
 	if err != nil {
-		if es.IsNotFound(err) {
-			return errors.New(NotFoundError)
-		}
 		return err
 	}
+	// Success
+	return nil
+}
+
+func (con *ModelV7) Get(database, collection, id string, result interface{}) error {
+	_, err := con.GetWithVersion(database, collection, id, result)
+	// Success
+	return err
+}
+
+func (con *ModelV7) GetWithVersion(database, _, id string, result interface{}) (*Version, error) {
+	res, err := con.model.Get(database, id)
+	if errors.Is(err, ErrNotFound) {
+		// Same error as the Find paths for a missing document
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !res.Found {
+		return nil, ErrNotFound
+	}
 	err = json.Unmarshal(res.Source, result)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Success
-	return nil
+	return newVersion(res.SeqNo, res.PrimaryTerm), nil
 }
 
 func (con *ModelV7) Exists(database, _, id string) (bool, error) {
@@ -47,101 +66,210 @@ func (con *ModelV7) Count(database, _ string, query Query) (int64, error) {
 	return con.model.Count(database, query)
 }
 
-func (con *ModelV7) FindOne(database, _ string, query Query, sorts []string, result interface{}) error {
-	res, err := con.model.SearchOffset(database, query, sorts, 0, 1)
-	if err != nil || res.Hits == nil {
-		return err
+func (con *ModelV7) FindOne(database, collection string, query Query, sorts []string, result interface{}) error {
+	_, err := con.FindOneWithOptions(database, collection, query, sorts, SearchOptions{}, result)
+	// Success
+	return err
+}
+
+func (con *ModelV7) FindOneWithOptions(database, _ string, query Query, sorts []string, opts SearchOptions, result interface{}) (*Hit, error) {
+	res, err := con.model.SearchWithOptions(database, query, sorts, 0, 1, opts)
+	if err != nil {
+		return nil, err
 	}
-	if res.Hits.TotalHits.Value == 0 {
-		return errors.New(NotFoundError)
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return nil, ErrNotFound
 	}
 	err = json.Unmarshal(res.Hits.Hits[0].Source, result)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	hit := newHit(res.Hits.Hits[0])
 	// Success
-	return nil
+	return &hit, nil
+}
+
+func (con *ModelV7) FindPaging(database, collection string, query Query, sorts []string, page, size int, results interface{}) (int64, error) {
+	// Success
+	return con.FindOffset(database, collection, query, sorts, page*size, size, results)
 }
 
-func (con *ModelV7) FindPaging(database, _ string, query Query, sorts []string, page, size int, results interface{}) (int64, error) {
-	res, err := con.model.SearchPaging(database, query, sorts, page, size)
-	if err != nil || res.Hits == nil {
+func (con *ModelV7) FindPagingWithOptions(database, collection string, query Query, sorts []string, page, size int, opts SearchOptions, results interface{}) (*SearchResult, error) {
+	// Success
+	return con.FindOffsetWithOptions(database, collection, query, sorts, page*size, size, opts, results)
+}
+
+func (con *ModelV7) FindOffset(database, collection string, query Query, sorts []string, offset, size int, results interface{}) (int64, error) {
+	res, err := con.FindOffsetWithOptions(database, collection, query, sorts, offset, size, SearchOptions{}, results)
+	if res == nil {
 		return 0, err
 	}
-	if res.Hits.TotalHits.Value == 0 {
-		return 0, errors.New(NotFoundError)
+	// Success
+	return res.Total, err
+}
+
+func (con *ModelV7) FindOffsetWithOptions(database, _ string, query Query, sorts []string, offset, size int, opts SearchOptions, results interface{}) (*SearchResult, error) {
+	res, err := con.model.SearchWithOptions(database, query, sorts, offset, size, opts)
+	if err != nil {
+		return nil, err
 	}
-	resultType := reflect.TypeOf(results)
-	resultValue := reflect.ValueOf(results)
-	resultElemType := resultType.Elem().Elem()
-	if resultType.Kind() != reflect.Ptr {
-		return 0, errors.New(ResultNotAPointer)
+	if res.Hits == nil || totalHits(res) == 0 {
+		return nil, ErrNotFound
+	}
+	result := &SearchResult{Total: totalHits(res), Hits: make([]Hit, 0, len(res.Hits.Hits))}
+	if err = decodeHits(res.Hits.Hits, results); err != nil {
+		return result, err
 	}
-	count := res.Hits.TotalHits.Value
 	for _, hit := range res.Hits.Hits {
-		itemValue := reflect.New(resultElemType)
-		err = json.Unmarshal(hit.Source, itemValue.Interface())
-		if err != nil {
-			return count, err
-		}
-		resultValue.Elem().Set(reflect.Append(resultValue.Elem(), itemValue.Elem()))
+		result.Hits = append(result.Hits, newHit(hit))
 	}
 	// Success
-	return count, nil
+	return result, nil
 }
 
-func (con *ModelV7) FindOffset(database, _ string, query Query, sorts []string, offset, size int, results interface{}) (int64, error) {
-	res, err := con.model.SearchOffset(database, query, sorts, offset, size)
-	if err != nil || res.Hits == nil {
-		return 0, err
+func (con *ModelV7) FindScroll(database, _ string, query Query, sorts []string, size int, scrollID, keepAlive string, results interface{}) (string, int64, error) {
+	res, err := con.model.SearchScroll(database, query, sorts, size, scrollID, keepAlive)
+	if err != nil {
+		return "", 0, err
 	}
-	if res.Hits.TotalHits.Value == 0 {
-		return 0, errors.New(NotFoundError)
+	if res.Hits == nil || totalHits(res) == 0 {
+		return "", 0, ErrNotFound
 	}
+	count := totalHits(res)
+	if err = decodeHits(res.Hits.Hits, results); err != nil {
+		return "", count, err
+	}
+	// Success
+	return res.ScrollId, count, nil
+}
+
+func (con *ModelV7) MultiGet(database, _ string, ids []string, results interface{}) ([]GetResult, error) {
 	resultType := reflect.TypeOf(results)
 	resultValue := reflect.ValueOf(results)
-	resultElemType := resultType.Elem().Elem()
-	if resultType.Kind() != reflect.Ptr {
-		return 0, errors.New(ResultNotAPointer)
+	if resultType == nil || resultType.Kind() != reflect.Ptr || resultType.Elem().Kind() != reflect.Slice {
+		return nil, ErrResultNotAPointer
 	}
-	count := res.Hits.TotalHits.Value
-	for _, hit := range res.Hits.Hits {
+	if len(ids) == 0 {
+		return []GetResult{}, nil
+	}
+	res, err := con.model.MultiGet(database, ids)
+	if err != nil {
+		return nil, err
+	}
+	resultElemType := resultType.Elem().Elem()
+	items := make([]GetResult, 0, len(res.Docs))
+	for _, doc := range res.Docs {
+		item := GetResult{Index: doc.Index, ID: doc.Id, Found: doc.Found}
 		itemValue := reflect.New(resultElemType)
-		err = json.Unmarshal(hit.Source, itemValue.Interface())
-		if err != nil {
-			return count, err
+		if doc.Error != nil {
+			item.Err = itemError(0, doc.Error, doc.Index, doc.Id)
+		} else if !doc.Found {
+			item.Err = ErrNotFound
+		} else if err = json.Unmarshal(doc.Source, itemValue.Interface()); err != nil {
+			item.Err = err
+		} else {
+			item.Version = newVersion(doc.SeqNo, doc.PrimaryTerm)
 		}
+		// Keep results aligned with ids
 		resultValue.Elem().Set(reflect.Append(resultValue.Elem(), itemValue.Elem()))
+		items = append(items, item)
+	}
+	// Success
+	return items, nil
+}
+
+func (con *ModelV7) MultiSearch(requests []SearchRequest) ([]SearchResponse, error) {
+	if len(requests) == 0 {
+		return []SearchResponse{}, nil
+	}
+	res, err := con.model.MultiSearch(requests)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Responses) != len(requests) {
+		return nil, ErrResponseMismatch
+	}
+	responses := make([]SearchResponse, 0, len(requests))
+	for idx, item := range res.Responses {
+		response := SearchResponse{}
+		if item.Error != nil {
+			response.Err = itemError(item.Status, item.Error, requests[idx].Database, "")
+		} else if item.Hits == nil || totalHits(item) == 0 {
+			response.Err = ErrNotFound
+		} else {
+			response.Total = totalHits(item)
+			response.Hits = make([]Hit, 0, len(item.Hits.Hits))
+			for _, hit := range item.Hits.Hits {
+				response.Hits = append(response.Hits, newHit(hit))
+			}
+			if requests[idx].Results != nil {
+				response.Err = decodeHits(item.Hits.Hits, requests[idx].Results)
+			}
+		}
+		responses = append(responses, response)
+	}
+	// Success
+	return responses, nil
+}
+
+func (con *ModelV7) Complete(database, _, field, prefix string, size int, fuzzy bool) ([]Suggestion, error) {
+	// Success
+	return con.model.Complete(database, field, prefix, size, fuzzy)
+}
+
+func (con *ModelV7) SuggestTerm(database, _, field, text string, size int) ([]Suggestion, error) {
+	// Success
+	return con.model.SuggestTerm(database, field, text, size)
+}
+
+func (con *ModelV7) SuggestPhrase(database, _, field, text string, size int) ([]Suggestion, error) {
+	// Success
+	return con.model.SuggestPhrase(database, field, text, size)
+}
+
+func (con *ModelV7) SearchAsYouType(database, _, field, text string, size int, results interface{}) (int64, error) {
+	res, err := con.model.SearchAsYouType(database, field, text, size)
+	if err != nil {
+		return 0, err
+	}
+	if res.Hits == nil || totalHits(res) == 0 {
+		return 0, ErrNotFound
+	}
+	count := totalHits(res)
+	if err = decodeHits(res.Hits.Hits, results); err != nil {
+		return count, err
 	}
 	// Success
 	return count, nil
 }
 
-func (con *ModelV7) FindScroll(database, _ string, query Query, sorts []string, size int, scrollID, keepAlive string, results interface{}) (string, int64, error) {
-	res, err := con.model.SearchScroll(database, query, sorts, size, scrollID, keepAlive)
-	if err != nil || res.Hits == nil {
-		return "", 0, err
+func totalHits(res *es.SearchResult) int64 {
+	if res.Hits == nil {
+		return 0
 	}
-	if res.Hits.TotalHits.Value == 0 {
-		return "", 0, errors.New(NotFoundError)
+	if res.Hits.TotalHits == nil {
+		return int64(len(res.Hits.Hits))
 	}
+	// Success
+	return res.Hits.TotalHits.Value
+}
+
+func decodeHits(hits []*es.SearchHit, results interface{}) error {
 	resultType := reflect.TypeOf(results)
 	resultValue := reflect.ValueOf(results)
-	resultElemType := resultType.Elem().Elem()
-	if resultType.Kind() != reflect.Ptr {
-		return "", 0, errors.New(ResultNotAPointer)
+	if resultType == nil || resultType.Kind() != reflect.Ptr || resultType.Elem().Kind() != reflect.Slice {
+		return ErrResultNotAPointer
 	}
-	count := res.Hits.TotalHits.Value
-	for _, hit := range res.Hits.Hits {
+	resultElemType := resultType.Elem().Elem()
+	for _, hit := range hits {
 		itemValue := reflect.New(resultElemType)
-		err = json.Unmarshal(hit.Source, itemValue.Interface())
-		if err != nil {
-			return "", count, err
+		if err := json.Unmarshal(hit.Source, itemValue.Interface()); err != nil {
+			return err
 		}
 		resultValue.Elem().Set(reflect.Append(resultValue.Elem(), itemValue.Elem()))
 	}
 	// Success
-	return res.ScrollId, count, nil
+	return nil
 }
 
 func (con *ModelV7) InsertOne(database, _ string, doc Document) error {
@@ -153,6 +281,15 @@ func (con *ModelV7) InsertOne(database, _ string, doc Document) error {
 	return nil
 }
 
+func (con *ModelV7) InsertOneWithOptions(database, _ string, doc Document, opts WriteOptions) (*Version, error) {
+	res, err := con.model.IndexWithOptions(database, doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Success
+	return &Version{SeqNo: res.SeqNo, PrimaryTerm: res.PrimaryTerm}, nil
+}
+
 func (con *ModelV7) InsertMany(database, _ string, docs []Document) error {
 	bulk := con.model.Bulk()
 	for idx := range docs {
@@ -175,6 +312,15 @@ func (con *ModelV7) UpdateByID(database, _, id string, update interface{}, upser
 	return nil
 }
 
+func (con *ModelV7) UpdateByIDWithOptions(database, _, id string, update interface{}, opts WriteOptions) (*Version, error) {
+	res, err := con.model.UpdateWithOptions(database, id, update, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Success
+	return &Version{SeqNo: res.SeqNo, PrimaryTerm: res.PrimaryTerm}, nil
+}
+
 func (con *ModelV7) UpdateOne(database, _ string, query Query, update interface{}, upsert bool) error {
 	// TODO
 	return nil
@@ -194,6 +340,15 @@ func (con *ModelV7) DeleteByID(database, _, id string) error {
 	return nil
 }
 
+func (con *ModelV7) DeleteByIDWithOptions(database, _, id string, opts WriteOptions) error {
+	_, err := con.model.DeleteByIDWithOptions(database, id, opts)
+	if err != nil {
+		return err
+	}
+	// Success
+	return nil
+}
+
 func (con *ModelV7) DeleteMany(database, _ string, query Query) error {
 	_, err := con.model.DeleteByQuery(database, query)
 	if err != nil {
@@ -0,0 +1,98 @@
+package elastic
+
+import (
+	"context"
+
+	es "github.com/olivere/elastic/v7"
+)
+
+const (
+	suggestName = "suggest"
+)
+
+func (con *ES) Complete(index, field, prefix string, size int, fuzzy bool) ([]Suggestion, error) {
+	suggester := es.NewCompletionSuggester(suggestName).
+		Field(field).
+		Size(suggestSize(size)).
+		SkipDuplicates(true)
+	if fuzzy {
+		suggester = suggester.PrefixWithEditDistance(prefix, "AUTO")
+	} else {
+		suggester = suggester.Prefix(prefix)
+	}
+	// Success
+	return con.suggest(index, suggester)
+}
+
+func (con *ES) SuggestTerm(index, field, text string, size int) ([]Suggestion, error) {
+	suggester := es.NewTermSuggester(suggestName).
+		Field(field).
+		Text(text).
+		Size(suggestSize(size))
+	// Success
+	return con.suggest(index, suggester)
+}
+
+func (con *ES) SuggestPhrase(index, field, text string, size int) ([]Suggestion, error) {
+	suggester := es.NewPhraseSuggester(suggestName).
+		Field(field).
+		Text(text).
+		Size(suggestSize(size))
+	// Success
+	return con.suggest(index, suggester)
+}
+
+func (con *ES) SearchAsYouType(index, field, text string, size int) (*es.SearchResult, error) {
+	query := Query{
+		"multi_match": M{
+			"query": text,
+			"type":  "bool_prefix",
+			"fields": []string{
+				field,
+				field + "._2gram",
+				field + "._3gram",
+			},
+		},
+	}
+	// Success
+	return con.SearchOffset(index, query, nil, 0, size)
+}
+
+func (con *ES) suggest(index string, suggester es.Suggester) ([]Suggestion, error) {
+	res, err := con.model.Search().
+		Index(index).
+		Suggester(suggester).
+		Size(0).
+		Do(context.Background())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	suggestions := make([]Suggestion, 0)
+	for _, entry := range res.Suggest[suggestName] {
+		for _, option := range entry.Options {
+			suggestion := Suggestion{
+				Input:  entry.Text,
+				Text:   option.Text,
+				Score:  option.Score,
+				Freq:   option.Freq,
+				Index:  option.Index,
+				ID:     option.Id,
+				Source: option.Source,
+			}
+			if suggestion.Score == 0 {
+				suggestion.Score = option.ScoreUnderscore
+			}
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	// Success
+	return suggestions, nil
+}
+
+func suggestSize(size int) int {
+	if size <= 0 {
+		return 5
+	}
+	// Success
+	return size
+}
@@ -1,6 +1,12 @@
 package mongo
 
+import "errors"
+
 const (
 	NotFoundError     = "not found"
 	ResultNotAPointer = "result not a pointer"
 )
+
+var (
+	ErrNotFound = errors.New(NotFoundError)
+)
@@ -15,8 +15,11 @@ type Database interface {
 	UpdateByID(database, collection string, id interface{}, update interface{}) error
 	UpdateOne(database, collection string, query *bson.M, update interface{}, upsert bool) error
 	UpdateMany(database, collection string, query *bson.M, update interface{}, upsert bool) error
+	// FindOneAndUpdate atomically updates the first match and decodes it after the update
+	FindOneAndUpdate(database, collection string, query *bson.M, sorts []string, update interface{}, result interface{}) error
 	DeleteByID(database, collection string, id interface{}) error
 	DeleteOne(database, collection string, query *bson.M) error
 	DeleteMany(database, collection string, query *bson.M) error
 	Aggregate(database, collection string, pipeline []*bson.M, results interface{}) error
+	WithTransaction(fn func(tx Database) error) error
 }
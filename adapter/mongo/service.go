@@ -14,6 +14,8 @@ import (
 type (
 	Model struct {
 		model *mongo.Client
+		// ctx carries the session inside WithTransaction
+		ctx context.Context
 	}
 )
 
@@ -50,11 +52,41 @@ func NewService(conf Config) (Database, error) {
 	}
 }
 
+func (con *Model) context() context.Context {
+	if con.ctx == nil {
+		return context.Background()
+	}
+	// Success
+	return con.ctx
+}
+
+// WithTransaction runs fn in a multi-document transaction, every call on the
+// Database passed to fn joins it. Transactions require a replica set.
+func (con *Model) WithTransaction(fn func(tx Database) error) error {
+	if con.ctx != nil {
+		// Already inside a transaction
+		return fn(con)
+	}
+	session, err := con.model.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	_, err = session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&Model{model: con.model, ctx: sc})
+	})
+	if err != nil {
+		return err
+	}
+	// Success
+	return nil
+}
+
 func (con *Model) CreateIndex(database, collection string, index *bson.M, unique bool) error {
 	opts := options.Index()
 	opts.SetBackground(true)
 	opts.SetUnique(unique)
-	_, err := con.model.Database(database).Collection(collection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+	_, err := con.model.Database(database).Collection(collection).Indexes().CreateOne(con.context(), mongo.IndexModel{
 		Keys:    index,
 		Options: opts,
 	})
@@ -66,10 +98,10 @@ func (con *Model) CreateIndex(database, collection string, index *bson.M, unique
 }
 
 func (con *Model) Get(database, collection, id string, result interface{}) error {
-	res := con.model.Database(database).Collection(collection).FindOne(context.Background(), &bson.M{"_id": id})
+	res := con.model.Database(database).Collection(collection).FindOne(con.context(), &bson.M{"_id": id})
 	if err := res.Err(); err != nil {
 		if strings.Contains(err.Error(), "no documents") {
-			return errors.New(NotFoundError)
+			return ErrNotFound
 		}
 		return err
 	}
@@ -81,7 +113,7 @@ func (con *Model) Get(database, collection, id string, result interface{}) error
 }
 
 func (con *Model) Count(database, collection string, query *bson.M) (int64, error) {
-	res, err := con.model.Database(database).Collection(collection).CountDocuments(context.Background(), query)
+	res, err := con.model.Database(database).Collection(collection).CountDocuments(con.context(), query)
 	if err != nil {
 		return 0, err
 	}
@@ -103,10 +135,10 @@ func (con *Model) FindOne(database, collection string, query *bson.M, sorts []st
 		}
 		opts.SetSort(s)
 	}
-	res := con.model.Database(database).Collection(collection).FindOne(context.Background(), query, opts)
+	res := con.model.Database(database).Collection(collection).FindOne(con.context(), query, opts)
 	if err := res.Err(); err != nil {
 		if strings.Contains(err.Error(), "no documents") {
-			return errors.New(NotFoundError)
+			return ErrNotFound
 		}
 		return err
 	}
@@ -137,10 +169,10 @@ func (con *Model) FindMany(database, collection string, query *bson.M, sorts []s
 		}
 		opts.SetSort(s)
 	}
-	cur, err := con.model.Database(database).Collection(collection).Find(context.Background(), query, opts)
+	cur, err := con.model.Database(database).Collection(collection).Find(con.context(), query, opts)
 	if err != nil {
 		if strings.Contains(err.Error(), "no documents") {
-			return 0, errors.New(NotFoundError)
+			return 0, ErrNotFound
 		}
 		return 0, err
 	}
@@ -151,7 +183,7 @@ func (con *Model) FindMany(database, collection string, query *bson.M, sorts []s
 		return 0, errors.New(ResultNotAPointer)
 	}
 	var total int64 = 0
-	for cur.Next(context.Background()) {
+	for cur.Next(con.context()) {
 		itemValue := reflect.New(resultElemType)
 		if err = cur.Decode(itemValue.Interface()); err != nil {
 			return 0, err
@@ -166,7 +198,7 @@ func (con *Model) FindMany(database, collection string, query *bson.M, sorts []s
 func (con *Model) InsertOne(database, collection string, doc Document) error {
 	opts := options.InsertOne()
 	opts.SetBypassDocumentValidation(true)
-	if _, err := con.model.Database(database).Collection(collection).InsertOne(context.Background(), doc, opts); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).InsertOne(con.context(), doc, opts); err != nil {
 		return err
 	}
 	// Success
@@ -184,7 +216,7 @@ func (con *Model) InsertMany(database, collection string, docs []Document, order
 	for _, doc := range docs {
 		documents = append(documents, doc)
 	}
-	if _, err := con.model.Database(database).Collection(collection).InsertMany(context.Background(), documents, opts); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).InsertMany(con.context(), documents, opts); err != nil {
 		return err
 	}
 	// Success
@@ -192,7 +224,7 @@ func (con *Model) InsertMany(database, collection string, docs []Document, order
 }
 
 func (con *Model) UpdateByID(database, collection string, id interface{}, update interface{}) error {
-	if _, err := con.model.Database(database).Collection(collection).UpdateByID(context.Background(), id, update); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).UpdateByID(con.context(), id, update); err != nil {
 		return err
 	}
 	// Success
@@ -203,7 +235,7 @@ func (con *Model) UpdateOne(database, collection string, query *bson.M, update i
 	opts := options.Update()
 	opts.SetUpsert(upsert)
 	opts.SetBypassDocumentValidation(true)
-	if _, err := con.model.Database(database).Collection(collection).UpdateOne(context.Background(), query, update); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).UpdateOne(con.context(), query, update); err != nil {
 		return err
 	}
 	// Success
@@ -214,7 +246,7 @@ func (con *Model) UpdateMany(database, collection string, query *bson.M, update
 	opts := options.Update()
 	opts.SetUpsert(upsert)
 	opts.SetBypassDocumentValidation(true)
-	_, err := con.model.Database(database).Collection(collection).UpdateMany(context.Background(), query, update)
+	_, err := con.model.Database(database).Collection(collection).UpdateMany(con.context(), query, update)
 	if err != nil {
 		return err
 	}
@@ -222,8 +254,36 @@ func (con *Model) UpdateMany(database, collection string, query *bson.M, update
 	return nil
 }
 
+func (con *Model) FindOneAndUpdate(database, collection string, query *bson.M, sorts []string, update interface{}, result interface{}) error {
+	opts := options.FindOneAndUpdate()
+	opts.SetReturnDocument(options.After)
+	if sorts != nil && len(sorts) > 0 {
+		s := bson.D{}
+		for _, sort := range sorts {
+			if strings.HasPrefix(sort, "-") {
+				s = append(s, bson.E{Key: strings.TrimPrefix(sort, "-"), Value: -1})
+			} else if strings.HasPrefix(sort, "+") {
+				s = append(s, bson.E{Key: strings.TrimPrefix(sort, "+"), Value: 1})
+			}
+		}
+		opts.SetSort(s)
+	}
+	res := con.model.Database(database).Collection(collection).FindOneAndUpdate(con.context(), query, update, opts)
+	if err := res.Err(); err != nil {
+		if strings.Contains(err.Error(), "no documents") {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := res.Decode(result); err != nil {
+		return err
+	}
+	// Success
+	return nil
+}
+
 func (con *Model) DeleteByID(database, collection string, id interface{}) error {
-	if _, err := con.model.Database(database).Collection(collection).DeleteOne(context.Background(), bson.M{"_id": id}); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).DeleteOne(con.context(), bson.M{"_id": id}); err != nil {
 		return err
 	}
 	// Success
@@ -231,7 +291,7 @@ func (con *Model) DeleteByID(database, collection string, id interface{}) error
 }
 
 func (con *Model) DeleteOne(database, collection string, query *bson.M) error {
-	if _, err := con.model.Database(database).Collection(collection).DeleteOne(context.Background(), query); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).DeleteOne(con.context(), query); err != nil {
 		return err
 	}
 	// Success
@@ -239,7 +299,7 @@ func (con *Model) DeleteOne(database, collection string, query *bson.M) error {
 }
 
 func (con *Model) DeleteMany(database, collection string, query *bson.M) error {
-	if _, err := con.model.Database(database).Collection(collection).DeleteMany(context.Background(), query); err != nil {
+	if _, err := con.model.Database(database).Collection(collection).DeleteMany(con.context(), query); err != nil {
 		return err
 	}
 	// Success
@@ -249,7 +309,7 @@ func (con *Model) DeleteMany(database, collection string, query *bson.M) error {
 func (con *Model) Aggregate(database, collection string, pipeline []*bson.M, results interface{}) error {
 	opts := options.Aggregate()
 	opts.SetBypassDocumentValidation(true)
-	cur, err := con.model.Database(database).Collection(collection).Aggregate(context.Background(), pipeline, opts)
+	cur, err := con.model.Database(database).Collection(collection).Aggregate(con.context(), pipeline, opts)
 	if err != nil {
 		return err
 	}
@@ -259,7 +319,7 @@ func (con *Model) Aggregate(database, collection string, pipeline []*bson.M, res
 	if resultType.Kind() != reflect.Ptr {
 		return errors.New(ResultNotAPointer)
 	}
-	for cur.Next(context.Background()) {
+	for cur.Next(con.context()) {
 		itemValue := reflect.New(resultElemType)
 		if err = cur.Decode(itemValue.Interface()); err != nil {
 			return err
@@ -0,0 +1,73 @@
+package outbox
+
+import "github.com/h14yhv/golang-lib/clock"
+
+type Config struct {
+	Database   string `json:"database" yaml:"database"`
+	Collection string `json:"collection" yaml:"collection"`
+	// BatchSize is the number of records published per relay pass
+	BatchSize int64 `json:"batch_size" yaml:"batch_size"`
+	// Interval is the poll delay when the outbox is drained
+	Interval clock.Duration `json:"interval" yaml:"interval"`
+	// RetryInterval is the first delay after a failed publish, doubled after
+	// every failure up to MaxRetryInterval
+	RetryInterval    clock.Duration `json:"retry_interval" yaml:"retry_interval"`
+	MaxRetryInterval clock.Duration `json:"max_retry_interval" yaml:"max_retry_interval"`
+	// LockTimeout is how long a relay owns a claimed record, another relay may
+	// claim it again once it expires
+	LockTimeout clock.Duration `json:"lock_timeout" yaml:"lock_timeout"`
+	// MaxAttempts marks a record failed after that many publish failures, 0 retries forever
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+}
+
+func (conf *Config) collection() string {
+	if conf.Collection == "" {
+		return DefaultCollection
+	}
+	// Success
+	return conf.Collection
+}
+
+func (conf *Config) batchSize() int64 {
+	if conf.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	// Success
+	return conf.BatchSize
+}
+
+func (conf *Config) interval() clock.Duration {
+	if conf.Interval <= 0 {
+		return DefaultInterval
+	}
+	// Success
+	return conf.Interval
+}
+
+func (conf *Config) lockTimeout() clock.Duration {
+	if conf.LockTimeout <= 0 {
+		return DefaultLockTimeout
+	}
+	// Success
+	return conf.LockTimeout
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (conf *Config) backoff(attempts int) clock.Duration {
+	delay := conf.RetryInterval
+	if delay <= 0 {
+		delay = DefaultRetryInterval
+	}
+	limit := conf.MaxRetryInterval
+	if limit <= 0 {
+		limit = DefaultMaxRetryInterval
+	}
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		return limit
+	}
+	// Success
+	return delay
+}
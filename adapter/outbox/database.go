@@ -0,0 +1,28 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+)
+
+type Record struct {
+	ID          string         `json:"id" bson:"_id"`
+	Exchange    string         `json:"exchange" bson:"exchange"`
+	RoutingKey  string         `json:"routing_key" bson:"routing_key"`
+	Message     rabbit.Message `json:"message" bson:"message"`
+	Status      string         `json:"status" bson:"status"`
+	Attempts    int            `json:"attempts" bson:"attempts"`
+	LastError   string         `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	NextAttempt time.Time      `json:"next_attempt" bson:"next_attempt"`
+	// LockID and LockedUntil are the claim of the relay publishing the record
+	LockID      string    `json:"lock_id,omitempty" bson:"lock_id,omitempty"`
+	LockedUntil time.Time `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	SentAt      time.Time `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+}
+
+func (doc *Record) GetID() interface{} {
+	// Success
+	return doc.ID
+}
@@ -0,0 +1,18 @@
+package outbox
+
+import "github.com/h14yhv/golang-lib/clock"
+
+const (
+	Module = "OUTBOX"
+
+	DefaultCollection       = "outbox"
+	DefaultBatchSize        = 100
+	DefaultInterval         = clock.Second
+	DefaultRetryInterval    = 5 * clock.Second
+	DefaultMaxRetryInterval = 10 * clock.Minute
+	DefaultLockTimeout      = clock.Minute
+
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
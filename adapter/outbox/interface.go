@@ -0,0 +1,20 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/h14yhv/golang-lib/adapter/mongo"
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+)
+
+type Outbox interface {
+	// EnsureIndex creates the index used by the relay query
+	EnsureIndex() error
+	// Add stores a message for publishing. Pass the Database given by
+	// mongo.WithTransaction so the message commits with the business write.
+	Add(tx mongo.Database, exchange, key string, message rabbit.Message) error
+	// Relay publishes one batch of due records and returns how many were sent
+	Relay() (int, error)
+	// Run relays until ctx is done
+	Run(ctx context.Context) error
+}
@@ -0,0 +1,251 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/h14yhv/golang-lib/adapter/mongo"
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+type outboxService struct {
+	logger    log.Logger
+	config    Config
+	db        mongo.Database
+	publisher rabbit.Service
+}
+
+// NewService relays records through publisher, one confirmed attempt per
+// relay pass. Relays claim records one at a time, so several instances can
+// run side by side. Delivery is at least once: a crash between publish and
+// marking the record sent, or a publish outliving LockTimeout, which is
+// logged, republishes it, so consumers should dedupe on MessageID. A nil
+// logger logs to stdout.
+func NewService(conf Config, db mongo.Database, publisher rabbit.Service, logger log.Logger) Outbox {
+	if logger == nil {
+		logger, _ = log.New(Module, log.DebugLevel, true, os.Stdout)
+	}
+	// Success
+	return &outboxService{
+		logger:    logger,
+		config:    conf,
+		db:        db,
+		publisher: publisher,
+	}
+}
+
+func (o *outboxService) EnsureIndex() error {
+	// Success
+	return o.db.CreateIndex(o.config.Database, o.config.collection(), &bson.M{"status": mongo.Ascending, "next_attempt": mongo.Ascending}, false)
+}
+
+func (o *outboxService) Add(tx mongo.Database, exchange, key string, message rabbit.Message) error {
+	if tx == nil {
+		tx = o.db
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if message.MessageID == "" {
+		message.MessageID = id.String()
+	}
+	if message.Timestamp.IsZero() {
+		message.Timestamp = now
+	}
+	record := &Record{
+		ID:          id.String(),
+		Exchange:    exchange,
+		RoutingKey:  key,
+		Message:     message,
+		Status:      StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	}
+	// Success
+	return tx.InsertOne(o.config.Database, o.config.collection(), record)
+}
+
+func (o *outboxService) Relay() (int, error) {
+	sent := 0
+	for i := int64(0); i < o.config.batchSize(); i++ {
+		record, err := o.claim()
+		if err != nil {
+			if errors.Is(err, mongo.ErrNotFound) {
+				break
+			}
+			return sent, err
+		}
+		message := record.Message
+		message.Headers = headers(message.Headers)
+		// A single attempt, a failing record backs off through next_attempt
+		// instead of holding the batch in the publisher retries
+		if err = o.publisher.PublishOnce(record.Exchange, record.RoutingKey, message); err != nil {
+			o.logger.Errorf("publish record %s failed, reason: %v", record.ID, err)
+			if err = o.fail(record, err); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		update := bson.M{
+			"$set":   bson.M{"status": StatusSent, "sent_at": time.Now().UTC()},
+			"$unset": bson.M{"lock_id": "", "locked_until": ""},
+		}
+		settled, err := o.settle(record, update)
+		if err != nil {
+			return sent, err
+		}
+		if settled {
+			sent++
+		}
+	}
+	// Success
+	return sent, nil
+}
+
+// claim atomically takes the next due record that no other relay holds
+func (o *outboxService) claim() (Record, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return Record{}, err
+	}
+	now := time.Now().UTC()
+	query := &bson.M{
+		"status":       StatusPending,
+		"next_attempt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"locked_until": bson.M{"$exists": false}},
+			bson.M{"locked_until": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"lock_id":      id.String(),
+		"locked_until": now.Add(time.Duration(o.config.lockTimeout())),
+	}}
+	var record Record
+	err = o.db.FindOneAndUpdate(o.config.Database, o.config.collection(), query, []string{"+next_attempt", "+created_at"}, update, &record)
+	// Success
+	return record, err
+}
+
+// owned matches the record only while this relay still holds its claim
+func (o *outboxService) owned(record Record) *bson.M {
+	// Success
+	return &bson.M{"_id": record.ID, "lock_id": record.LockID}
+}
+
+func (o *outboxService) fail(record Record, reason error) error {
+	attempts := record.Attempts + 1
+	set := bson.M{
+		"attempts":     attempts,
+		"last_error":   reason.Error(),
+		"next_attempt": time.Now().UTC().Add(time.Duration(o.config.backoff(attempts))),
+	}
+	if o.config.MaxAttempts > 0 && attempts >= o.config.MaxAttempts {
+		set["status"] = StatusFailed
+	}
+	update := bson.M{"$set": set, "$unset": bson.M{"lock_id": "", "locked_until": ""}}
+	_, err := o.settle(record, update)
+	// Success
+	return err
+}
+
+// settle applies update while this relay still holds the claim. A claim that
+// expired meanwhile is logged and left alone, another relay may publish the
+// record again.
+func (o *outboxService) settle(record Record, update bson.M) (bool, error) {
+	var current Record
+	err := o.db.FindOneAndUpdate(o.config.Database, o.config.collection(), o.owned(record), nil, update, &current)
+	if err != nil && errors.Is(err, mongo.ErrNotFound) {
+		o.logger.Warningf("record %s claim expired before it was settled, it may be published again", record.ID)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Success
+	return true, nil
+}
+
+func (o *outboxService) Run(ctx context.Context) error {
+	for {
+		sent, err := o.Relay()
+		if err != nil {
+			o.logger.Errorf("relay failed, reason: %v", err)
+		}
+		// A full batch means more records are probably due
+		if err == nil && int64(sent) == o.config.batchSize() {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		timer := time.NewTimer(time.Duration(o.config.interval()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// headers converts the bson types a decoded record holds, such as
+// primitive.D for a nested table, into types amqp can encode
+func headers(table map[string]interface{}) map[string]interface{} {
+	if table == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(table))
+	for key, value := range table {
+		result[key] = header(value)
+	}
+	// Success
+	return result
+}
+
+func header(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.D:
+		table := make(amqp.Table, len(v))
+		for _, e := range v {
+			table[e.Key] = header(e.Value)
+		}
+		return table
+	case primitive.M:
+		return amqp.Table(headers(v))
+	case map[string]interface{}:
+		return amqp.Table(headers(v))
+	case amqp.Table:
+		return amqp.Table(headers(v))
+	case primitive.A:
+		return header([]interface{}(v))
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = header(item)
+		}
+		return list
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case primitive.Binary:
+		return v.Data
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Decimal128:
+		return v.String()
+	case int:
+		return int64(v)
+	}
+	// Success
+	return value
+}
@@ -0,0 +1,317 @@
+package outbox
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/h14yhv/golang-lib/adapter/mongo"
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/adapter/rabbit/rabbittest"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+// memoryDatabase keeps records as bson documents, so they decode with the
+// types a real collection returns. It supports the queries of the relay.
+type memoryDatabase struct {
+	mongo.Database
+	mutex   sync.Mutex
+	records map[string]bson.M
+}
+
+// hookPublisher runs hook before publishing through the broker
+type hookPublisher struct {
+	rabbit.Service
+	hook func()
+}
+
+func newMemoryDatabase() *memoryDatabase {
+	// Success
+	return &memoryDatabase{records: make(map[string]bson.M)}
+}
+
+func (db *memoryDatabase) CreateIndex(_, _ string, _ *bson.M, _ bool) error {
+	// Success
+	return nil
+}
+
+func (db *memoryDatabase) InsertOne(_, _ string, doc mongo.Document) error {
+	var record bson.M
+	if err := convert(doc, &record); err != nil {
+		return err
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.records[doc.GetID().(string)] = record
+	// Success
+	return nil
+}
+
+func (db *memoryDatabase) FindOneAndUpdate(_, _ string, query *bson.M, sorts []string, update interface{}, result interface{}) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	matched := make([]bson.M, 0)
+	for _, record := range db.records {
+		if matches(record, *query) {
+			matched = append(matched, record)
+		}
+	}
+	if len(matched) == 0 {
+		return mongo.ErrNotFound
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		for _, field := range sorts {
+			a, b := timeOf(matched[i][field[1:]]), timeOf(matched[j][field[1:]])
+			if !a.Equal(b) {
+				return a.Before(b)
+			}
+		}
+		return false
+	})
+	record := matched[0]
+	for op, fields := range update.(bson.M) {
+		for key, value := range fields.(bson.M) {
+			if op == "$unset" {
+				delete(record, key)
+			} else {
+				record[key] = value
+			}
+		}
+	}
+	// Success
+	return convert(record, result)
+}
+
+func (db *memoryDatabase) record(t *testing.T, id string) Record {
+	t.Helper()
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var record Record
+	if err := convert(db.records[id], &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	// Success
+	return record
+}
+
+func (db *memoryDatabase) ids() []string {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	ids := make([]string, 0, len(db.records))
+	for id := range db.records {
+		ids = append(ids, id)
+	}
+	// Success
+	return ids
+}
+
+func (p *hookPublisher) PublishOnce(exchange, key string, message rabbit.Message) error {
+	p.hook()
+	// Success
+	return p.Service.PublishOnce(exchange, key, message)
+}
+
+func convert(from, to interface{}) error {
+	data, err := bson.Marshal(from)
+	if err != nil {
+		return err
+	}
+	// Success
+	return bson.Unmarshal(data, to)
+}
+
+func matches(record, query bson.M) bool {
+	for key, condition := range query {
+		if key == "$or" {
+			any := false
+			for _, alternative := range condition.(bson.A) {
+				any = any || matches(record, alternative.(bson.M))
+			}
+			if !any {
+				return false
+			}
+			continue
+		}
+		value, exists := record[key]
+		operators, ok := condition.(bson.M)
+		if !ok {
+			if !exists || value != condition {
+				return false
+			}
+			continue
+		}
+		for op, operand := range operators {
+			switch op {
+			case "$exists":
+				if exists != operand.(bool) {
+					return false
+				}
+			case "$lte":
+				if !exists || timeOf(value).After(timeOf(operand)) {
+					return false
+				}
+			}
+		}
+	}
+	// Success
+	return true
+}
+
+func timeOf(value interface{}) time.Time {
+	if v, ok := value.(primitive.DateTime); ok {
+		return v.Time()
+	}
+	t, _ := value.(time.Time)
+	// Success
+	return t
+}
+
+func newOutbox(t *testing.T, conf Config, db mongo.Database, publisher rabbit.Service) *outboxService {
+	t.Helper()
+	// Success
+	return NewService(conf, db, publisher, nil).(*outboxService)
+}
+
+func newBroker(t *testing.T) *rabbittest.Broker {
+	t.Helper()
+	broker := rabbittest.New()
+	if err := broker.DeclareQueue("jobs", true, 0, 0); err != nil {
+		t.Fatalf("declare queue: %v", err)
+	}
+	// Success
+	return broker
+}
+
+func TestRelay(t *testing.T) {
+	db, broker := newMemoryDatabase(), newBroker(t)
+	o := newOutbox(t, Config{}, db, broker)
+	for i := 0; i < 3; i++ {
+		if err := o.Add(nil, "", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	if sent, err := o.Relay(); err != nil || sent != 3 {
+		t.Fatalf("relay returned %d, %v, want 3 sent", sent, err)
+	}
+	if messages := broker.Messages("jobs"); len(messages) != 3 {
+		t.Fatalf("queue holds %d messages, want 3", len(messages))
+	}
+	for _, id := range db.ids() {
+		if record := db.record(t, id); record.Status != StatusSent || record.LockID != "" {
+			t.Fatalf("record %+v not marked sent and released", record)
+		}
+	}
+	if sent, err := o.Relay(); err != nil || sent != 0 {
+		t.Fatalf("second relay returned %d, %v, want nothing sent", sent, err)
+	}
+}
+
+func TestRelaySkipsClaimedRecords(t *testing.T) {
+	db, broker := newMemoryDatabase(), newBroker(t)
+	first := newOutbox(t, Config{LockTimeout: 20 * clock.Millisecond}, db, broker)
+	second := newOutbox(t, Config{}, db, broker)
+	if err := first.Add(nil, "", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := first.claim(); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if sent, err := second.Relay(); err != nil || sent != 0 {
+		t.Fatalf("relay of a claimed record returned %d, %v, want nothing sent", sent, err)
+	}
+	// The claim expires, another relay takes the record over
+	time.Sleep(30 * time.Millisecond)
+	if sent, err := second.Relay(); err != nil || sent != 1 {
+		t.Fatalf("relay after the claim expired returned %d, %v, want 1 sent", sent, err)
+	}
+}
+
+func TestRelayDoesNotCountLostClaims(t *testing.T) {
+	db, broker := newMemoryDatabase(), newBroker(t)
+	thief := newOutbox(t, Config{}, db, broker)
+	publisher := &hookPublisher{Service: broker}
+	o := newOutbox(t, Config{LockTimeout: clock.Millisecond}, db, publisher)
+	publisher.hook = func() {
+		// The publish outlives the claim and another relay takes the record
+		time.Sleep(5 * time.Millisecond)
+		if _, err := thief.claim(); err != nil {
+			t.Errorf("claim: %v", err)
+		}
+	}
+	if err := o.Add(nil, "", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if sent, err := o.Relay(); err != nil || sent != 0 {
+		t.Fatalf("relay with a lost claim returned %d, %v, want nothing sent", sent, err)
+	}
+	if record := db.record(t, db.ids()[0]); record.Status != StatusPending || record.LockID == "" {
+		t.Fatalf("record %+v was settled over the new claim", record)
+	}
+}
+
+func TestRelayBacksOffFailures(t *testing.T) {
+	db, broker := newMemoryDatabase(), newBroker(t)
+	o := newOutbox(t, Config{RetryInterval: clock.Minute}, db, broker)
+	if err := o.Add(nil, "missing", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	before := time.Now()
+	if sent, err := o.Relay(); err != nil || sent != 0 {
+		t.Fatalf("relay returned %d, %v, want nothing sent", sent, err)
+	}
+	record := db.record(t, db.ids()[0])
+	if record.Status != StatusPending || record.Attempts != 1 || record.LastError == "" || record.LockID != "" {
+		t.Fatalf("failed record %+v", record)
+	}
+	// Stored times keep millisecond precision
+	if record.NextAttempt.Before(before.Add(time.Minute).Truncate(time.Millisecond)) {
+		t.Fatalf("next attempt %v is not a retry interval away", record.NextAttempt)
+	}
+	if sent, err := o.Relay(); err != nil || sent != 0 {
+		t.Fatalf("relay before the next attempt returned %d, %v", sent, err)
+	}
+	if record = db.record(t, record.ID); record.Attempts != 1 {
+		t.Fatalf("record was retried before its next attempt, %d attempts", record.Attempts)
+	}
+}
+
+func TestRelayMaxAttempts(t *testing.T) {
+	db, broker := newMemoryDatabase(), newBroker(t)
+	o := newOutbox(t, Config{RetryInterval: clock.Millisecond, MaxAttempts: 2}, db, broker)
+	if err := o.Add(nil, "missing", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := o.Relay(); err != nil {
+			t.Fatalf("relay: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if record := db.record(t, db.ids()[0]); record.Status != StatusFailed || record.Attempts != 2 {
+		t.Fatalf("record %+v, want failed after 2 attempts", record)
+	}
+}
+
+func TestHeadersFromBSON(t *testing.T) {
+	record := Record{ID: "1", Message: rabbit.Message{Headers: map[string]interface{}{
+		"tags":    []interface{}{"a", "b"},
+		"at":      time.Now(),
+		"nested":  map[string]interface{}{"count": 1},
+		"attempt": int32(2),
+	}}}
+	var decoded Record
+	if err := convert(record, &decoded); err != nil {
+		t.Fatalf("bson round trip: %v", err)
+	}
+	if err := amqp.Table(decoded.Message.Headers).Validate(); err == nil {
+		t.Fatal("decoded headers are valid amqp, the test does not cover bson types")
+	}
+	if err := amqp.Table(headers(decoded.Message.Headers)).Validate(); err != nil {
+		t.Fatalf("normalized headers are not valid amqp: %v", err)
+	}
+}
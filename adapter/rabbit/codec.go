@@ -0,0 +1,227 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	// Codec encodes message bodies for one content type. JSON, msgpack,
+	// protobuf and plain text are built in, register others with
+	// RegisterCodec.
+	Codec interface {
+		ContentType() string
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	jsonCodec struct{}
+
+	msgpackCodec struct{}
+
+	protobufCodec struct{}
+
+	// textCodec passes bodies through as string or []byte, it decodes the
+	// text/plain default of Publish. Other targets are decoded as JSON, which
+	// producers calling Publish with their own encoding usually send.
+	textCodec struct{}
+)
+
+var (
+	codecMutex sync.RWMutex
+	codecs     = map[string]Codec{
+		MIMEApplicationJSON:     jsonCodec{},
+		MIMEApplicationMsgpack:  msgpackCodec{},
+		MIMEApplicationProtobuf: protobufCodec{},
+		MIMETextPlain:           textCodec{},
+	}
+
+	deliveryType = reflect.TypeOf(Delivery{})
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func RegisterCodec(codec Codec) {
+	codecMutex.Lock()
+	defer codecMutex.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecFor returns the codec for a content type, parameters such as charset
+// are ignored and an empty content type means JSON
+func CodecFor(contentType string) (Codec, error) {
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	if contentType == "" {
+		contentType = MIMEApplicationJSON
+	}
+	codecMutex.RLock()
+	defer codecMutex.RUnlock()
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, &CodecError{ContentType: contentType}
+	}
+	// Success
+	return codec, nil
+}
+
+func (jsonCodec) ContentType() string {
+	// Success
+	return MIMEApplicationJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	// Success
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	// Success
+	return json.Unmarshal(data, v)
+}
+
+func (msgpackCodec) ContentType() string {
+	// Success
+	return MIMEApplicationMsgpack
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	// Success
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	// Success
+	return msgpack.Unmarshal(data, v)
+}
+
+func (protobufCodec) ContentType() string {
+	// Success
+	return MIMEApplicationProtobuf
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, &CodecError{ContentType: MIMEApplicationProtobuf, Type: reflect.TypeOf(v)}
+	}
+	// Success
+	return proto.Marshal(msg)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return &CodecError{ContentType: MIMEApplicationProtobuf, Type: reflect.TypeOf(v)}
+	}
+	// Success
+	return proto.Unmarshal(data, msg)
+}
+
+func (textCodec) ContentType() string {
+	// Success
+	return MIMETextPlain
+}
+
+func (textCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	}
+	// Success
+	return nil, &CodecError{ContentType: MIMETextPlain, Type: reflect.TypeOf(v)}
+}
+
+func (textCodec) Unmarshal(data []byte, v interface{}) error {
+	switch value := v.(type) {
+	case *[]byte:
+		*value = append([]byte{}, data...)
+		return nil
+	case *string:
+		*value = string(data)
+		return nil
+	}
+	// Success
+	return json.Unmarshal(data, v)
+}
+
+func (r *rabbitConnection) PublishJSON(exchange, key string, v interface{}) error {
+	// Success
+	return r.PublishObject(exchange, key, Message{ContentType: MIMEApplicationJSON}, v)
+}
+
+// PublishObject encodes v with the codec of message.ContentType into the
+// message body, the other message properties are published as given
+func (r *rabbitConnection) PublishObject(exchange, key string, message Message, v interface{}) error {
+	if message.ContentType == "" {
+		message.ContentType = MIMEApplicationJSON
+	}
+	codec, err := CodecFor(message.ContentType)
+	if err != nil {
+		return err
+	}
+	if message.Body, err = codec.Marshal(v); err != nil {
+		return err
+	}
+	// Success
+	return r.Publish(exchange, key, message)
+}
+
+// ConsumeObject decodes every delivery into a new value of the handler
+// argument type, see ObjectHandler. Decode failures are dead lettered.
+func (r *rabbitConnection) ConsumeObject(ctx context.Context, queue string, options ConsumeOptions, handler interface{}) (Subscription, error) {
+	h, err := ObjectHandler(handler)
+	if err != nil {
+		return nil, err
+	}
+	// Success
+	return r.ConsumeDelivery(ctx, queue, options, h)
+}
+
+// ObjectHandler adapts a func(T) error or func(T, Delivery) error, where T may
+// be a pointer, to a Handler decoding the body with the codec of its content
+// type. Decode failures return a DecodeError, which rejects the message.
+func ObjectHandler(handler interface{}) (Handler, error) {
+	fn := reflect.ValueOf(handler)
+	kind := reflect.TypeOf(handler)
+	if kind == nil || kind.Kind() != reflect.Func || kind.NumOut() != 1 || kind.Out(0) != errorType ||
+		kind.NumIn() < 1 || kind.NumIn() > 2 || (kind.NumIn() == 2 && kind.In(1) != deliveryType) {
+		return nil, &HandlerError{Type: kind}
+	}
+	argument := kind.In(0)
+	// Success
+	return func(delivery Delivery) error {
+		target := argument
+		if target.Kind() == reflect.Ptr {
+			target = target.Elem()
+		}
+		value := reflect.New(target)
+		codec, err := CodecFor(delivery.ContentType)
+		if err == nil {
+			err = codec.Unmarshal(delivery.Body, value.Interface())
+		}
+		if err != nil {
+			return &DecodeError{ContentType: delivery.ContentType, Err: err}
+		}
+		if argument.Kind() != reflect.Ptr {
+			value = value.Elem()
+		}
+		in := []reflect.Value{value}
+		if kind.NumIn() == 2 {
+			in = append(in, reflect.ValueOf(delivery))
+		}
+		if out := fn.Call(in)[0]; !out.IsNil() {
+			return out.Interface().(error)
+		}
+		return nil
+	}, nil
+}
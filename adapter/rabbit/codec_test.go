@@ -0,0 +1,77 @@
+package rabbit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type codecItem struct {
+	Name  string `json:"name" msgpack:"name"`
+	Count int    `json:"count" msgpack:"count"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, contentType := range []string{MIMEApplicationJSON, MIMEApplicationMsgpack, MIMEApplicationJSON + "; charset=utf-8"} {
+		codec, err := CodecFor(contentType)
+		if err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		data, err := codec.Marshal(codecItem{Name: "a", Count: 2})
+		if err != nil {
+			t.Fatalf("%s marshal: %v", contentType, err)
+		}
+		var result codecItem
+		if err = codec.Unmarshal(data, &result); err != nil {
+			t.Fatalf("%s unmarshal: %v", contentType, err)
+		}
+		if result != (codecItem{Name: "a", Count: 2}) {
+			t.Fatalf("%s round trip returned %+v", contentType, result)
+		}
+	}
+}
+
+func TestTextCodec(t *testing.T) {
+	codec, err := CodecFor(MIMETextPlain)
+	if err != nil {
+		t.Fatalf("text codec: %v", err)
+	}
+	var text string
+	if err = codec.Unmarshal([]byte("hello"), &text); err != nil || text != "hello" {
+		t.Fatalf("unmarshal returned %q, %v", text, err)
+	}
+	var raw []byte
+	if err = codec.Unmarshal([]byte("hello"), &raw); err != nil || string(raw) != "hello" {
+		t.Fatalf("unmarshal returned %q, %v", raw, err)
+	}
+	var item codecItem
+	if err = codec.Unmarshal([]byte(`{"name":"a","count":2}`), &item); err != nil || item != (codecItem{Name: "a", Count: 2}) {
+		t.Fatalf("unmarshal of legacy JSON returned %+v, %v", item, err)
+	}
+	if _, err = codec.Marshal(codecItem{}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("marshal of a struct returned %v, want ErrUnsupportedCodec", err)
+	}
+}
+
+func TestObjectHandlerDecodeFailureRejects(t *testing.T) {
+	handler, err := ObjectHandler(func(item *codecItem) error { return nil })
+	if err != nil {
+		t.Fatalf("object handler: %v", err)
+	}
+	err = handler(Delivery{ContentType: MIMEApplicationJSON, Body: []byte("{")})
+	if !errors.Is(err, ErrDecode) || !errors.Is(err, ErrReject) {
+		t.Fatalf("decode failure returned %v, want a rejecting DecodeError", err)
+	}
+	var decoded codecItem
+	handler, _ = ObjectHandler(func(item codecItem, delivery Delivery) error {
+		decoded = item
+		return nil
+	})
+	body, _ := (msgpackCodec{}).Marshal(codecItem{Name: "b", Count: 3})
+	if err = handler(Delivery{ContentType: MIMEApplicationMsgpack, Body: body}); err != nil {
+		t.Fatalf("handler returned %v", err)
+	}
+	if !reflect.DeepEqual(decoded, codecItem{Name: "b", Count: 3}) {
+		t.Fatalf("handler decoded %+v", decoded)
+	}
+}
@@ -1,13 +1,35 @@
 package rabbit
 
-import "fmt"
+import (
+	"fmt"
 
-type Config struct {
-	Secure   bool   `json:"secure" yaml:"secure"`
-	Address  string `json:"address" yaml:"address"`
-	Username string `json:"username" yaml:"username"`
-	Password string `json:"password" yaml:"password"`
-}
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	Config struct {
+		Secure    bool            `json:"secure" yaml:"secure"`
+		Address   string          `json:"address" yaml:"address"`
+		Username  string          `json:"username" yaml:"username"`
+		Password  string          `json:"password" yaml:"password"`
+		Publish   PublishConfig   `json:"publish" yaml:"publish"`
+		Reconnect ReconnectConfig `json:"reconnect" yaml:"reconnect"`
+	}
+
+	ReconnectConfig struct {
+		// Interval is the first delay, doubled after every failure up to MaxInterval
+		Interval    clock.Duration `json:"interval" yaml:"interval"`
+		MaxInterval clock.Duration `json:"max_interval" yaml:"max_interval"`
+	}
+
+	PublishConfig struct {
+		Timeout       clock.Duration `json:"timeout" yaml:"timeout"`
+		MaxRetries    int            `json:"max_retries" yaml:"max_retries"`
+		RetryInterval clock.Duration `json:"retry_interval" yaml:"retry_interval"`
+		// Channels is the size of the publishing channel pool
+		Channels int `json:"channels" yaml:"channels"`
+	}
+)
 
 func (conf *Config) String() string {
 	protocol := "amqp"
@@ -17,3 +39,67 @@ func (conf *Config) String() string {
 	// Success
 	return fmt.Sprintf("%s://%s:%s@%s", protocol, conf.Username, conf.Password, conf.Address)
 }
+
+func (conf *PublishConfig) timeout() clock.Duration {
+	if conf.Timeout <= 0 {
+		return DefaultPublishTimeout
+	}
+	// Success
+	return conf.Timeout
+}
+
+func (conf *PublishConfig) maxRetries() int {
+	if conf.MaxRetries < 0 {
+		return 0
+	}
+	if conf.MaxRetries == 0 {
+		return DefaultPublishMaxRetries
+	}
+	// Success
+	return conf.MaxRetries
+}
+
+func (conf *PublishConfig) retryInterval() clock.Duration {
+	if conf.RetryInterval <= 0 {
+		return SchedulePublish
+	}
+	// Success
+	return conf.RetryInterval
+}
+
+func (conf *PublishConfig) channels() int {
+	if conf.Channels <= 0 {
+		return DefaultPublishChannels
+	}
+	// Success
+	return conf.Channels
+}
+
+func (conf *ReconnectConfig) interval() clock.Duration {
+	if conf.Interval <= 0 {
+		return ScheduleReconnect
+	}
+	// Success
+	return conf.Interval
+}
+
+func (conf *ReconnectConfig) maxInterval() clock.Duration {
+	if conf.MaxInterval <= 0 {
+		return DefaultReconnectMaxInterval
+	}
+	// Success
+	return conf.MaxInterval
+}
+
+// backoff returns the delay before the given attempt, starting from 0
+func (conf *ReconnectConfig) backoff(attempt int) clock.Duration {
+	delay := conf.interval()
+	for i := 0; i < attempt && delay < conf.maxInterval(); i++ {
+		delay *= 2
+	}
+	if delay > conf.maxInterval() {
+		return conf.maxInterval()
+	}
+	// Success
+	return delay
+}
@@ -0,0 +1,287 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type consumer struct {
+	service *rabbitConnection
+	queue   string
+	tag     string
+	options ConsumeOptions
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mutex   sync.Mutex
+	channel *amqp.Channel
+	closed  chan *amqp.Error
+	// err is the first handler or channel error, guarded by mutex
+	err error
+}
+
+func (r *rabbitConnection) ConsumeContext(ctx context.Context, queue string, options ConsumeOptions, callback Consumer) (Subscription, error) {
+	// Success
+	return r.ConsumeDelivery(ctx, queue, options, func(delivery Delivery) error {
+		return callback(delivery.Body)
+	})
+}
+
+func (r *rabbitConnection) ConsumeDelivery(ctx context.Context, queue string, options ConsumeOptions, handler Handler) (Subscription, error) {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.PrefetchCount <= 0 {
+		options.PrefetchCount = options.Concurrency
+	}
+	if len(options.Middlewares) > 0 {
+		handler = Chain(options.Middlewares...)(handler)
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	c := &consumer{
+		service: r,
+		queue:   queue,
+		tag:     id.String(),
+		options: options,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	// Close waits for the consumers it stops, registering must not race it
+	r.mutex.RLock()
+	if r.ctx.Err() != nil {
+		r.mutex.RUnlock()
+		c.cancel()
+		return nil, ErrConnectionClosed
+	}
+	r.consumers.Add(1)
+	r.mutex.RUnlock()
+	deliveries, err := c.subscribe()
+	if err != nil {
+		c.cancel()
+		r.consumers.Done()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-r.ctx.Done():
+			c.fail(ErrConnectionClosed)
+			c.cancel()
+		case <-c.done:
+		}
+	}()
+	go c.run(deliveries)
+	// Success
+	return c, nil
+}
+
+func (c *consumer) subscribe() (<-chan amqp.Delivery, error) {
+	connection, err := c.service.conn()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err = channel.Qos(c.options.PrefetchCount, 0, false); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	deliveries, err := channel.Consume(c.queue, c.tag, c.options.AutoAck, c.options.Exclusive, false, false, nil)
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	// Buffered, the channel sends its error before closing the deliveries
+	closed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	c.mutex.Lock()
+	c.channel = channel
+	c.closed = closed
+	c.mutex.Unlock()
+	// Success
+	return deliveries, nil
+}
+
+func (c *consumer) run(deliveries <-chan amqp.Delivery) {
+	defer c.service.consumers.Done()
+	defer close(c.done)
+	for {
+		c.serve(deliveries)
+		if c.ctx.Err() != nil {
+			return
+		}
+		// Channel or connection lost, subscribe again
+		for attempt := 0; ; attempt++ {
+			if !sleep(c.ctx, c.service.config.Reconnect.backoff(attempt)) {
+				return
+			}
+			var err error
+			if deliveries, err = c.subscribe(); err == nil {
+				c.service.logger.Infof("consumer %s resubscribed to queue %s", c.tag, c.queue)
+				break
+			}
+			c.service.logger.Errorf("consumer %s subscribe failed, reason: %v", c.tag, err)
+		}
+	}
+}
+
+// serve dispatches deliveries to the handler goroutines until the delivery
+// channel closes, either because the consumer was cancelled or because the
+// channel died, then waits for in-flight callbacks before returning
+func (c *consumer) serve(deliveries <-chan amqp.Delivery) {
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			c.mutex.Lock()
+			if err := c.channel.Cancel(c.tag, false); err != nil {
+				c.service.logger.Errorf("consumer %s cancel failed, reason: %v", c.tag, err)
+			}
+			c.mutex.Unlock()
+		case <-finished:
+		}
+	}()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < c.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range deliveries {
+				c.handle(msg)
+			}
+		}()
+	}
+	wg.Wait()
+	close(finished)
+	c.mutex.Lock()
+	select {
+	case err, ok := <-c.closed:
+		if ok && err != nil && c.err == nil {
+			c.err = err
+		}
+	default:
+	}
+	_ = c.channel.Close()
+	c.mutex.Unlock()
+}
+
+func (c *consumer) handle(msg amqp.Delivery) {
+	delivery := newDelivery(c.queue, msg)
+	// Stopping only cancels the AMQP consumer, in-flight handlers run to
+	// completion so their messages are acked
+	delivery.Context = context.Background()
+	err := c.handler(delivery)
+	if err != nil {
+		c.fail(err)
+	}
+	if c.options.AutoAck {
+		return
+	}
+	if err == nil {
+		// Ack
+		if err = msg.Ack(false); err != nil {
+			c.fail(err)
+			c.service.logger.Errorf("consumer %s ack failed, reason: %v", c.tag, err)
+		}
+		return
+	}
+	// Rejected messages, undecodable ones for instance, skip retries
+	permanent := errors.Is(err, ErrReject)
+	if c.options.Retry != nil {
+		retryErr := c.retry(msg, err, permanent)
+		if retryErr == nil {
+			return
+		}
+		c.service.logger.Errorf("consumer %s retry failed, reason: %v", c.tag, retryErr)
+		// The retry queues are unreachable, pause briefly before requeueing
+		// so the message does not spin on redelivery without stalling the
+		// worker for the whole retry delay
+		sleep(c.ctx, RetryRequeueDelay)
+	}
+	// Requeue, or reject to the queue dead letter exchange
+	if err = msg.Nack(false, !permanent || c.options.Retry != nil); err != nil {
+		c.service.logger.Errorf("consumer %s nack failed, reason: %v", c.tag, err)
+	}
+}
+
+// fail keeps the first error for Stop and Wait
+func (c *consumer) fail(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *consumer) error() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Success
+	return c.err
+}
+
+func (c *consumer) Stop() error {
+	c.cancel()
+	<-c.done
+	// Success
+	return c.error()
+}
+
+func (c *consumer) Done() <-chan struct{} {
+	// Success
+	return c.done
+}
+
+func (c *consumer) Wait() error {
+	<-c.done
+	// Success
+	return c.error()
+}
+
+func newDelivery(queue string, msg amqp.Delivery) Delivery {
+	// Success
+	return Delivery{
+		Body:            msg.Body,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Mode:            msg.DeliveryMode,
+		Priority:        msg.Priority,
+		Headers:         msg.Headers,
+		CorrelationID:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageID:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserID:          msg.UserId,
+		AppID:           msg.AppId,
+		Queue:           queue,
+		Exchange:        msg.Exchange,
+		RoutingKey:      msg.RoutingKey,
+		Redelivered:     msg.Redelivered,
+		DeliveryTag:     msg.DeliveryTag,
+	}
+}
+
+func sleep(ctx context.Context, duration clock.Duration) bool {
+	timer := time.NewTimer(time.Duration(duration))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -3,6 +3,7 @@ package rabbit
 import (
 	"github.com/streadway/amqp"
 
+	"github.com/h14yhv/golang-lib/adapter/rabbit/internal/backoff"
 	"github.com/h14yhv/golang-lib/clock"
 )
 
@@ -12,9 +13,64 @@ const (
 	SchedulePublish   = 3 * clock.Second
 	ScheduleConsume   = 3 * clock.Second
 
-	MIMEApplicationJSON = "application/json"
-	MIMETextPlain       = "text/plain"
+	DefaultHeartbeat   = 10 * clock.Second
+	DefaultDialTimeout = 30 * clock.Second
+
+	DefaultPublishTimeout    = 5 * clock.Second
+	DefaultPublishMaxRetries = 3
+	DefaultPublishChannels   = 4
+
+	DefaultCallTimeout          = 30 * clock.Second
+	DefaultReconnectMaxInterval = 30 * clock.Second
+	DefaultIdempotencyTTL       = 24 * clock.Hour
+
+	KeyPrefixProcessed = "rabbit:processed:"
+
+	OutcomeSuccess  = "success"
+	OutcomeFailed   = "failed"
+	OutcomeRejected = "rejected"
+
+	DefaultRetryMaxAttempts  = backoff.DefaultMaxAttempts
+	DefaultRetryInitialDelay = backoff.DefaultInitialDelay
+	DefaultRetryMaxDelay     = backoff.DefaultMaxDelay
+	DefaultRetryMultiplier   = backoff.DefaultMultiplier
+	// RetryRequeueDelay bounds the pause before requeueing a message whose
+	// retry publish failed
+	RetryRequeueDelay = 100 * clock.Millisecond
+
+	HeaderRetryAttempts = "x-retry-attempts"
+	HeaderLastError     = "x-last-error"
+	HeaderRPCError      = "x-rpc-error"
+
+	DirectReplyTo = "amq.rabbitmq.reply-to"
+
+	SuffixRetry      = ".retry"
+	SuffixDeadLetter = ".dlq"
+
+	ExchangeDirect  = amqp.ExchangeDirect
+	ExchangeFanout  = amqp.ExchangeFanout
+	ExchangeTopic   = amqp.ExchangeTopic
+	ExchangeHeaders = amqp.ExchangeHeaders
+
+	QueueClassic = "classic"
+	QueueQuorum  = "quorum"
+	QueueStream  = "stream"
+
+	OverflowDropHead         = "drop-head"
+	OverflowRejectPublish    = "reject-publish"
+	OverflowRejectPublishDLX = "reject-publish-dlx"
+
+	MIMEApplicationJSON     = "application/json"
+	MIMEApplicationMsgpack  = "application/msgpack"
+	MIMEApplicationProtobuf = "application/x-protobuf"
+	MIMETextPlain           = "text/plain"
 
 	Transient  = amqp.Transient
 	Persistent = amqp.Persistent
 )
+
+const (
+	EventConnectionLost EventType = iota + 1
+	EventConnectionRestored
+	EventTopologyFailed
+)
@@ -0,0 +1,194 @@
+package rabbit
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+const (
+	PublishNackedError    = "publish nacked by broker"
+	PublishTimeoutError   = "publish confirm timeout"
+	UnroutableError       = "message unroutable"
+	ChannelClosedError    = "channel closed"
+	ConnectionClosedError = "connection closed"
+	RemoteCallError       = "remote call failed"
+	UnsupportedCodecError = "unsupported codec"
+	InvalidHandlerError   = "invalid handler"
+	DecodeFailedError     = "decode failed"
+	RejectedError         = "message rejected"
+	HandlerPanicError     = "handler panic"
+	HandlerTimeoutError   = "handler timeout"
+	InvalidOptionError    = "invalid option"
+)
+
+var (
+	ErrPublishNacked    = errors.New(PublishNackedError)
+	ErrPublishTimeout   = errors.New(PublishTimeoutError)
+	ErrUnroutable       = errors.New(UnroutableError)
+	ErrChannelClosed    = errors.New(ChannelClosedError)
+	ErrConnectionClosed = errors.New(ConnectionClosedError)
+	ErrUnsupportedCodec = errors.New(UnsupportedCodecError)
+	ErrInvalidHandler   = errors.New(InvalidHandlerError)
+	ErrDecode           = errors.New(DecodeFailedError)
+	// ErrReject marks failures that must not be redelivered, wrap it with
+	// Reject or match it with errors.Is
+	ErrReject         = errors.New(RejectedError)
+	ErrHandlerTimeout = errors.New(HandlerTimeoutError)
+	ErrInvalidOption  = errors.New(InvalidOptionError)
+)
+
+type (
+	ReturnError struct {
+		Exchange   string
+		RoutingKey string
+		ReplyCode  uint16
+		ReplyText  string
+	}
+
+	PublishError struct {
+		Attempts int
+		Err      error
+	}
+
+	// CodecError reports a content type without codec, or a value the codec
+	// cannot handle when Type is set
+	CodecError struct {
+		ContentType string
+		Type        reflect.Type
+	}
+
+	HandlerError struct {
+		Type reflect.Type
+	}
+
+	DecodeError struct {
+		ContentType string
+		Err         error
+	}
+
+	RejectError struct {
+		Err error
+	}
+
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// RemoteError carries the handler error reported by a Serve peer
+	RemoteError struct {
+		Reason string
+	}
+
+	// OptionError is returned by New for an out of range option
+	OptionError struct {
+		Name  string
+		Value interface{}
+	}
+)
+
+func (e *ReturnError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: exchange %q, routing key %q, %d %s", UnroutableError, e.Exchange, e.RoutingKey, e.ReplyCode, e.ReplyText)
+}
+
+func (e *ReturnError) Is(target error) bool {
+	// Success
+	return target == ErrUnroutable
+}
+
+func (e *PublishError) Error() string {
+	// Success
+	return fmt.Sprintf("publish failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	// Success
+	return e.Err
+}
+
+func (e *RemoteError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: %s", RemoteCallError, e.Reason)
+}
+
+func (e *CodecError) Error() string {
+	if e.Type != nil {
+		return fmt.Sprintf("%s: %s cannot encode %v", UnsupportedCodecError, e.ContentType, e.Type)
+	}
+	// Success
+	return fmt.Sprintf("%s: %q", UnsupportedCodecError, e.ContentType)
+}
+
+func (e *CodecError) Is(target error) bool {
+	// Success
+	return target == ErrUnsupportedCodec
+}
+
+func (e *HandlerError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: %v, want func(T) error or func(T, Delivery) error", InvalidHandlerError, e.Type)
+}
+
+func (e *HandlerError) Is(target error) bool {
+	// Success
+	return target == ErrInvalidHandler
+}
+
+func (e *OptionError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: %s %v", InvalidOptionError, e.Name, e.Value)
+}
+
+func (e *OptionError) Is(target error) bool {
+	// Success
+	return target == ErrInvalidOption
+}
+
+func (e *DecodeError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: content type %q: %v", DecodeFailedError, e.ContentType, e.Err)
+}
+
+func (e *DecodeError) Is(target error) bool {
+	// Success
+	return target == ErrDecode || target == ErrReject
+}
+
+func (e *DecodeError) Unwrap() error {
+	// Success
+	return e.Err
+}
+
+// Reject wraps err so the consumer dead letters the message instead of
+// requeueing or retrying it
+func Reject(err error) error {
+	// Success
+	return &RejectError{Err: err}
+}
+
+func (e *RejectError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: %v", RejectedError, e.Err)
+}
+
+func (e *RejectError) Is(target error) bool {
+	// Success
+	return target == ErrReject
+}
+
+func (e *RejectError) Unwrap() error {
+	// Success
+	return e.Err
+}
+
+func (e *PanicError) Error() string {
+	// Success
+	return fmt.Sprintf("%s: %v", HandlerPanicError, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	// Success
+	return target == ErrReject
+}
@@ -0,0 +1,51 @@
+package rabbit
+
+import (
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+// IdempotencyStore is satisfied by redis.Service
+type IdempotencyStore interface {
+	SetNX(key, value string, ttl clock.Duration) (bool, error)
+	Delete(keys ...string) error
+}
+
+// Idempotent skips deliveries whose MessageID was already handled on the same
+// queue within ttl. The marker is set before the handler runs, so concurrent
+// duplicates are skipped too, and removed again when the handler fails so the
+// redelivery is processed. Deliveries without a MessageID always run.
+//
+// The marker trades a window of at-most-once for that: a process crashing
+// while the handler runs, or a marker removal failing after a handler error,
+// leaves the marker in place and the redelivery is skipped until ttl. Failed
+// removals are logged with the message id so it can be replayed.
+func Idempotent(store IdempotencyStore, ttl clock.Duration, logger log.Logger) Middleware {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	// Success
+	return func(next Handler) Handler {
+		return func(delivery Delivery) error {
+			if delivery.MessageID == "" {
+				return next(delivery)
+			}
+			key := KeyPrefixProcessed + delivery.Queue + ":" + delivery.MessageID
+			first, err := store.SetNX(key, delivery.MessageID, ttl)
+			if err != nil {
+				return err
+			}
+			if !first {
+				// Already processed
+				return nil
+			}
+			if err = next(delivery); err != nil {
+				if deleteErr := store.Delete(key); deleteErr != nil {
+					logger.Errorf("remove processed marker of message %s from queue %s failed, its redelivery will be skipped, reason: %v", delivery.MessageID, delivery.Queue, deleteErr)
+				}
+				return err
+			}
+			return nil
+		}
+	}
+}
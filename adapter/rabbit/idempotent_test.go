@@ -0,0 +1,77 @@
+package rabbit
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+type memoryStore struct {
+	mutex     sync.Mutex
+	keys      map[string]string
+	deleteErr error
+}
+
+func (s *memoryStore) SetNX(key, value string, ttl clock.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.keys[key]; ok {
+		return false, nil
+	}
+	s.keys[key] = value
+	// Success
+	return true, nil
+}
+
+func (s *memoryStore) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	for _, key := range keys {
+		delete(s.keys, key)
+	}
+	// Success
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	logger, _ := log.New(Module, log.DebugLevel, true, os.Stdout)
+	store := &memoryStore{keys: make(map[string]string)}
+	calls := 0
+	fail := errors.New("fail")
+	var result error
+	handler := Idempotent(store, clock.Minute, logger)(func(delivery Delivery) error {
+		calls++
+		return result
+	})
+	delivery := Delivery{Queue: "jobs", MessageID: "1"}
+	// A failed handler removes the marker so the redelivery runs
+	result = fail
+	if err := handler(delivery); !errors.Is(err, fail) {
+		t.Fatalf("handler returned %v, want the handler error", err)
+	}
+	result = nil
+	if err := handler(delivery); err != nil || calls != 2 {
+		t.Fatalf("redelivery returned %v after %d calls, want a second call", err, calls)
+	}
+	// A processed message is skipped
+	if err := handler(delivery); err != nil || calls != 2 {
+		t.Fatalf("duplicate returned %v after %d calls, want it skipped", err, calls)
+	}
+	// Messages without an id always run
+	if err := handler(Delivery{Queue: "jobs"}); err != nil || calls != 3 {
+		t.Fatalf("delivery without id returned %v after %d calls", err, calls)
+	}
+	// A failed marker removal still returns the handler error
+	store.deleteErr = errors.New("store down")
+	result = fail
+	if err := handler(Delivery{Queue: "jobs", MessageID: "2"}); !errors.Is(err, fail) {
+		t.Fatalf("handler returned %v, want the handler error", err)
+	}
+}
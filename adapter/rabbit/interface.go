@@ -1,14 +1,55 @@
 package rabbit
 
-import "github.com/h14yhv/golang-lib/clock"
+import (
+	"context"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
 
 type (
 	Service interface {
 		DeclareExchange(name, kind string, durable bool) error
+		DeclareExchangeWithOptions(name, kind string, options ExchangeOptions) error
 		DeclareQueue(name string, durable bool, priority int, ttl clock.Duration) error
+		DeclareQueueWithOptions(name string, options QueueOptions) error
+		DeclareRetryTopology(queue string, policy RetryPolicy) error
 		BindQueue(queue, exchange string) error
+		BindQueueWithOptions(queue, exchange string, options BindOptions) error
+		UnbindQueue(queue, exchange string, options BindOptions) error
+		BindExchange(destination, source string, options BindOptions) error
+		UnbindExchange(destination, source string, options BindOptions) error
+		DeleteExchange(name string, ifUnused bool) error
+		DeleteQueue(name string, ifUnused, ifEmpty bool) (int, error)
 		Publish(exchange, queue string, message Message) error
+		// PublishOnce makes a single confirmed attempt, for callers that retry
+		// on their own schedule
+		PublishOnce(exchange, key string, message Message) error
+		PublishJSON(exchange, key string, v interface{}) error
+		PublishObject(exchange, key string, message Message, v interface{}) error
 		Consume(queue string, auto bool, prefetchCount int, callback Consumer) error
+		ConsumeContext(ctx context.Context, queue string, options ConsumeOptions, callback Consumer) (Subscription, error)
+		ConsumeDelivery(ctx context.Context, queue string, options ConsumeOptions, handler Handler) (Subscription, error)
+		ConsumeObject(ctx context.Context, queue string, options ConsumeOptions, handler interface{}) (Subscription, error)
+		Call(ctx context.Context, exchange, routingKey string, message Message) (Delivery, error)
+		Serve(ctx context.Context, queue string, options ConsumeOptions, handler ReplyHandler) (Subscription, error)
+		// OnEvent registers a hook called synchronously from the connection monitor
+		OnEvent(hook EventHook)
+		// Close stops the connection monitor, drains the publisher pool and
+		// closes the connection. Consumers stop with ErrConnectionClosed.
+		Close() error
+	}
+	Subscription interface {
+		// Stop cancels the consumer and blocks until in-flight callbacks finish,
+		// it returns the first handler or channel error like Wait
+		Stop() error
+		Done() <-chan struct{}
+		Wait() error
 	}
 	Consumer func([]byte) error
+	Handler  func(Delivery) error
+	// Middleware wraps a Handler, see Chain
+	Middleware func(next Handler) Handler
+	// ReplyHandler returns the response published to the request ReplyTo
+	ReplyHandler func(Delivery) (Message, error)
+	EventHook    func(Event)
 )
@@ -0,0 +1,67 @@
+// Package backoff holds the retry policy arithmetic shared by the rabbit
+// service and the in-memory broker of rabbittest
+package backoff
+
+import (
+	"math"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+const (
+	DefaultMaxAttempts  = 5
+	DefaultInitialDelay = clock.Second
+	DefaultMaxDelay     = clock.Minute
+	DefaultMultiplier   = 2
+)
+
+// Policy mirrors rabbit.RetryPolicy, which converts to it
+type Policy struct {
+	MaxAttempts  int
+	InitialDelay clock.Duration
+	MaxDelay     clock.Duration
+	Multiplier   float64
+}
+
+// Attempts returns MaxAttempts or its default
+func (p Policy) Attempts() int {
+	if p.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	// Success
+	return p.MaxAttempts
+}
+
+func (p Policy) initialDelay() clock.Duration {
+	if p.InitialDelay <= 0 {
+		return DefaultInitialDelay
+	}
+	// Success
+	return p.InitialDelay
+}
+
+func (p Policy) maxDelay() clock.Duration {
+	if p.MaxDelay <= 0 {
+		return DefaultMaxDelay
+	}
+	// Success
+	return p.MaxDelay
+}
+
+func (p Policy) multiplier() float64 {
+	if p.Multiplier < 1 {
+		return DefaultMultiplier
+	}
+	// Success
+	return p.Multiplier
+}
+
+// Delay returns the wait before the given retry, starting from 1
+func (p Policy) Delay(retry int) clock.Duration {
+	delay := float64(p.initialDelay()) * math.Pow(p.multiplier(), float64(retry-1))
+	if delay > float64(p.maxDelay()) {
+		return p.maxDelay()
+	}
+	// Success
+	return clock.Duration(delay)
+}
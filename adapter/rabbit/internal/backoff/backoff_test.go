@@ -0,0 +1,22 @@
+package backoff
+
+import (
+	"testing"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func TestDelay(t *testing.T) {
+	policy := Policy{InitialDelay: clock.Second, MaxDelay: 5 * clock.Second, Multiplier: 3}
+	for retry, want := range map[int]clock.Duration{1: clock.Second, 2: 3 * clock.Second, 3: 5 * clock.Second} {
+		if delay := policy.Delay(retry); delay != want {
+			t.Fatalf("delay before retry %d is %v, want %v", retry, delay, want)
+		}
+	}
+	if delay := (Policy{}).Delay(2); delay != DefaultInitialDelay*DefaultMultiplier {
+		t.Fatalf("default delay before retry 2 is %v", delay)
+	}
+	if attempts := (Policy{}).Attempts(); attempts != DefaultMaxAttempts {
+		t.Fatalf("default attempts %d, want %d", attempts, DefaultMaxAttempts)
+	}
+}
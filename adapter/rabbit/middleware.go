@@ -0,0 +1,115 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"time"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+// MetricsHook observes every handled delivery, see Outcome
+type MetricsHook func(delivery Delivery, duration time.Duration, err error)
+
+// Chain composes middlewares, the first one is the outermost
+func Chain(middlewares ...Middleware) Middleware {
+	// Success
+	return func(next Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Outcome classifies a handler result as OutcomeSuccess, OutcomeFailed
+// (requeued or retried) or OutcomeRejected (never redelivered)
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrReject):
+		return OutcomeRejected
+	}
+	// Success
+	return OutcomeFailed
+}
+
+// Recover turns a handler panic into a PanicError, which rejects the message
+// instead of requeueing it, and logs the stack
+func Recover(logger log.Logger) Middleware {
+	// Success
+	return func(next Handler) Handler {
+		return func(delivery Delivery) (err error) {
+			defer func() {
+				if value := recover(); value != nil {
+					stack := debug.Stack()
+					logger.Errorf("panic handling message %s from queue %s: %v\n%s", delivery.MessageID, delivery.Queue, value, stack)
+					err = &PanicError{Value: value, Stack: stack}
+				}
+			}()
+			return next(delivery)
+		}
+	}
+}
+
+// Timeout bounds each handler call through Delivery.Context, handlers must
+// watch it for the timeout to take effect. The middleware waits for the
+// handler to return, so a redelivery never runs alongside a late handler.
+// A handler failing after the deadline returns ErrHandlerTimeout, one that
+// still succeeds is acked.
+func Timeout(timeout clock.Duration) Middleware {
+	// Success
+	return func(next Handler) Handler {
+		return func(delivery Delivery) error {
+			parent := delivery.Context
+			if parent == nil {
+				parent = context.Background()
+			}
+			ctx, cancel := context.WithTimeout(parent, time.Duration(timeout))
+			defer cancel()
+			delivery.Context = ctx
+			err := next(delivery)
+			if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrHandlerTimeout
+			}
+			return err
+		}
+	}
+}
+
+// Logging logs every delivery with its queue, duration and outcome
+func Logging(logger log.Logger) Middleware {
+	// Success
+	return func(next Handler) Handler {
+		return func(delivery Delivery) error {
+			start := time.Now()
+			err := next(delivery)
+			duration := time.Since(start)
+			if err != nil {
+				logger.Errorf("queue=%s message=%s duration=%s outcome=%s error=%v", delivery.Queue, delivery.MessageID, duration, Outcome(err), err)
+				return err
+			}
+			logger.Debugf("queue=%s message=%s duration=%s outcome=%s", delivery.Queue, delivery.MessageID, duration, OutcomeSuccess)
+			return nil
+		}
+	}
+}
+
+// Metrics reports every delivery to the given hooks
+func Metrics(hooks ...MetricsHook) Middleware {
+	// Success
+	return func(next Handler) Handler {
+		return func(delivery Delivery) error {
+			start := time.Now()
+			err := next(delivery)
+			duration := time.Since(start)
+			for _, hook := range hooks {
+				hook(delivery, duration, err)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+func TestTimeoutWaitsForHandler(t *testing.T) {
+	returned := false
+	handler := Timeout(10 * clock.Millisecond)(func(delivery Delivery) error {
+		<-delivery.Context.Done()
+		returned = true
+		return delivery.Context.Err()
+	})
+	err := handler(Delivery{Context: context.Background()})
+	if !errors.Is(err, ErrHandlerTimeout) {
+		t.Fatalf("handler returned %v, want ErrHandlerTimeout", err)
+	}
+	if !returned {
+		t.Fatal("timeout returned before the handler")
+	}
+	// A handler finishing in time is not affected
+	handler = Timeout(clock.Second)(func(delivery Delivery) error { return nil })
+	if err = handler(Delivery{}); err != nil {
+		t.Fatalf("handler returned %v, want nil", err)
+	}
+}
+
+func TestRecoverRejects(t *testing.T) {
+	logger, _ := log.New(Module, log.DebugLevel, true, os.Stdout)
+	handler := Chain(Recover(logger))(func(delivery Delivery) error { panic("boom") })
+	err := handler(Delivery{Queue: "jobs"})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || !errors.Is(err, ErrReject) || panicErr.Value != "boom" {
+		t.Fatalf("handler returned %v, want a rejecting PanicError", err)
+	}
+	if Outcome(err) != OutcomeRejected {
+		t.Fatalf("outcome %s, want %s", Outcome(err), OutcomeRejected)
+	}
+}
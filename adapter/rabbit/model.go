@@ -1,8 +1,120 @@
 package rabbit
 
-type Message struct {
-	Body        []byte
-	ContentType string
-	Mode        uint8
-	Priority    uint8
-}
+import (
+	"context"
+	"time"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	Message struct {
+		Body            []byte
+		ContentType     string
+		ContentEncoding string
+		Mode            uint8
+		Priority        uint8
+		Mandatory       bool
+		Headers         map[string]interface{}
+		CorrelationID   string
+		ReplyTo         string
+		// Expiration is the per-message TTL, zero means no expiry
+		Expiration clock.Duration
+		// MessageID defaults to a random UUID
+		MessageID string
+		Timestamp time.Time
+		Type      string
+		AppID     string
+	}
+
+	Delivery struct {
+		Body            []byte
+		ContentType     string
+		ContentEncoding string
+		Mode            uint8
+		Priority        uint8
+		Headers         map[string]interface{}
+		CorrelationID   string
+		ReplyTo         string
+		Expiration      string
+		MessageID       string
+		Timestamp       time.Time
+		Type            string
+		UserID          string
+		AppID           string
+		Queue           string
+		Exchange        string
+		RoutingKey      string
+		Redelivered     bool
+		DeliveryTag     uint64
+		// Context is not cancelled when the subscription stops, so in-flight
+		// handlers finish, but may be bounded by middlewares such as Timeout.
+		// It is never nil in consumer handlers.
+		Context context.Context
+	}
+
+	ConsumeOptions struct {
+		AutoAck       bool
+		Exclusive     bool
+		PrefetchCount int
+		// Concurrency is the number of goroutines running the callback, defaults to 1
+		Concurrency int
+		// Retry moves failed messages through the retry queues declared by
+		// DeclareRetryTopology instead of requeueing them immediately
+		Retry *RetryPolicy
+		// Middlewares wrap the handler, the first one is the outermost
+		Middlewares []Middleware
+	}
+
+	ExchangeOptions struct {
+		Durable    bool
+		AutoDelete bool
+		// Internal exchanges only receive messages from exchange bindings
+		Internal  bool
+		Arguments map[string]interface{}
+	}
+
+	QueueOptions struct {
+		Durable    bool
+		AutoDelete bool
+		Exclusive  bool
+		// Type is QueueClassic, QueueQuorum or QueueStream, empty uses the broker default
+		Type     string
+		Lazy     bool
+		Priority int
+		TTL      clock.Duration
+		// Expires deletes the queue after it has been unused for this long
+		Expires        clock.Duration
+		MaxLength      int
+		MaxLengthBytes int
+		// Overflow is OverflowDropHead, OverflowRejectPublish or OverflowRejectPublishDLX
+		Overflow  string
+		Arguments map[string]interface{}
+		// Dead lettering is enabled when either field is set, an empty
+		// exchange with a routing key targets the default exchange
+		DeadLetterExchange   string
+		DeadLetterRoutingKey string
+	}
+
+	BindOptions struct {
+		// RoutingKey is used as is, an empty key is valid for fanout and headers exchanges
+		RoutingKey string
+		Arguments  map[string]interface{}
+	}
+
+	EventType int
+
+	// Event reports connection state changes, Err is set when known
+	Event struct {
+		Type EventType
+		Err  error
+	}
+
+	RetryPolicy struct {
+		// MaxAttempts counts the first delivery, defaults to DefaultRetryMaxAttempts
+		MaxAttempts  int
+		InitialDelay clock.Duration
+		MaxDelay     clock.Duration
+		Multiplier   float64
+	}
+)
@@ -0,0 +1,106 @@
+package rabbit
+
+import (
+	"crypto/tls"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/log"
+)
+
+type (
+	// Option configures the connection built by New
+	Option func(*options)
+
+	options struct {
+		logger          log.Logger
+		tlsConfig       *tls.Config
+		connectionName  string
+		heartbeat       clock.Duration
+		vhost           string
+		connectAttempts int
+		connectInterval clock.Duration
+		dialTimeout     clock.Duration
+		// err is the first invalid option, returned by New
+		err error
+	}
+)
+
+func defaultOptions() options {
+	// Success
+	return options{
+		heartbeat:       DefaultHeartbeat,
+		connectAttempts: 1,
+		connectInterval: ScheduleReconnect,
+		dialTimeout:     DefaultDialTimeout,
+	}
+}
+
+// WithLogger replaces the default debug level stdout logger
+func WithLogger(logger log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithTLS dials amqps with the given config. Without it a secure Config
+// still verifies the server against the system roots.
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithConnectionName sets the client provided name shown by the management UI
+func WithConnectionName(name string) Option {
+	return func(o *options) {
+		o.connectionName = name
+	}
+}
+
+func WithHeartbeat(interval clock.Duration) Option {
+	return func(o *options) {
+		o.heartbeat = interval
+	}
+}
+
+// WithVhost overrides the vhost, the default is the vhost of the URL ("/")
+func WithVhost(vhost string) Option {
+	return func(o *options) {
+		o.vhost = vhost
+	}
+}
+
+// WithConnectRetry makes New try the first connection up to attempts times,
+// waiting interval between attempts, before returning the last error
+func WithConnectRetry(attempts int, interval clock.Duration) Option {
+	return func(o *options) {
+		o.connectAttempts = attempts
+		o.connectInterval = interval
+	}
+}
+
+// WithDialTimeout bounds the TCP dial and the AMQP handshake, 0 keeps
+// DefaultDialTimeout and a negative timeout makes New fail
+func WithDialTimeout(timeout clock.Duration) Option {
+	return func(o *options) {
+		if timeout < 0 {
+			o.invalid(&OptionError{Name: "dial timeout", Value: timeout})
+			return
+		}
+		o.dialTimeout = timeout
+	}
+}
+
+func (o *options) invalid(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+func (o *options) timeout() clock.Duration {
+	if o.dialTimeout <= 0 {
+		return DefaultDialTimeout
+	}
+	// Success
+	return o.dialTimeout
+}
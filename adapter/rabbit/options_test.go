@@ -0,0 +1,23 @@
+package rabbit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func TestDialTimeoutOption(t *testing.T) {
+	o := defaultOptions()
+	WithDialTimeout(0)(&o)
+	if o.err != nil || o.timeout() != DefaultDialTimeout {
+		t.Fatalf("zero timeout gave %v, %v, want the default", o.timeout(), o.err)
+	}
+	WithDialTimeout(clock.Second)(&o)
+	if o.timeout() != clock.Second {
+		t.Fatalf("timeout %v, want 1s", o.timeout())
+	}
+	if _, err := New(Config{Address: "localhost:1"}, WithDialTimeout(-clock.Second)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("New returned %v, want ErrInvalidOption", err)
+	}
+}
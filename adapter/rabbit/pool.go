@@ -0,0 +1,79 @@
+package rabbit
+
+import (
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// pool hands out confirm mode publishers, one caller at a time per channel.
+// Slots hold nil or dead publishers until they are reopened on the current
+// connection by the next caller, so reconnects need no coordination here.
+type pool struct {
+	items  chan *publisher
+	closed chan struct{}
+}
+
+func newPool(connection *amqp.Connection, size int) (*pool, error) {
+	p := &pool{items: make(chan *publisher, size), closed: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		item, err := newPublisher(connection)
+		if err != nil {
+			// Fill the remaining slots so close finds them all
+			for ; i < size; i++ {
+				p.items <- nil
+			}
+			p.close()
+			return nil, err
+		}
+		p.items <- item
+	}
+	// Success
+	return p, nil
+}
+
+func (p *pool) acquire(connect func() (*amqp.Connection, error), timeout time.Duration) (*publisher, error) {
+	var item *publisher
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.closed:
+		return nil, ErrConnectionClosed
+	default:
+	}
+	select {
+	case item = <-p.items:
+	case <-p.closed:
+		return nil, ErrConnectionClosed
+	case <-timer.C:
+		return nil, ErrPublishTimeout
+	}
+	if item != nil && !item.isClosed() {
+		return item, nil
+	}
+	connection, err := connect()
+	if err == nil {
+		item, err = newPublisher(connection)
+	}
+	if err != nil {
+		p.items <- nil
+		return nil, err
+	}
+	// Success
+	return item, nil
+}
+
+func (p *pool) release(item *publisher) {
+	p.items <- item
+}
+
+// close stops handing out publishers and closes every slot, waiting for the
+// ones in use to be released
+func (p *pool) close() {
+	close(p.closed)
+	for i := 0; i < cap(p.items); i++ {
+		if item := <-p.items; item != nil {
+			_ = item.close()
+		}
+	}
+}
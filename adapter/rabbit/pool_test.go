@@ -0,0 +1,33 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestPoolCloseWaitsForPublishers(t *testing.T) {
+	p := &pool{items: make(chan *publisher, 2), closed: make(chan struct{})}
+	p.items <- nil
+	// The second slot is in use by a publish
+	closed := make(chan struct{})
+	go func() {
+		p.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("close returned while a publisher was in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := p.acquire(func() (*amqp.Connection, error) { return nil, ErrConnectionClosed }, time.Second); err != ErrConnectionClosed {
+		t.Fatalf("acquire on a closing pool returned %v, want ErrConnectionClosed", err)
+	}
+	p.release(nil)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close did not return once the publisher was released")
+	}
+}
@@ -0,0 +1,127 @@
+package rabbit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+type publisher struct {
+	mutex    sync.Mutex
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	closed   chan *amqp.Error
+	sequence uint64
+}
+
+func newPublisher(connection *amqp.Connection) (*publisher, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err = channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	// Success
+	return &publisher{
+		channel:  channel,
+		confirms: channel.NotifyPublish(make(chan amqp.Confirmation, 64)),
+		returns:  channel.NotifyReturn(make(chan amqp.Return, 64)),
+		closed:   channel.NotifyClose(make(chan *amqp.Error, 1)),
+	}, nil
+}
+
+// publish sends one message and blocks until the broker confirms it
+func (p *publisher) publish(exchange, key string, mandatory bool, msg amqp.Publishing, timeout time.Duration) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.channel.Publish(exchange, key, mandatory, false, msg); err != nil {
+		return err
+	}
+	p.sequence++
+	// Success
+	return p.wait(p.sequence, msg.MessageId, timeout)
+}
+
+// wait blocks for the confirm of tag. A basic.return for a mandatory message
+// always precedes its ack and the client delivers both from one goroutine,
+// so once the confirm is received the return is already buffered. select
+// may still pick the confirm first, the pending returns are drained before
+// deciding.
+func (p *publisher) wait(tag uint64, messageID string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var returned *amqp.Return
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return ErrChannelClosed
+			}
+			if ret.MessageId == messageID {
+				returned = &ret
+			}
+		case confirm, ok := <-p.confirms:
+			if !ok {
+				return ErrChannelClosed
+			}
+			if confirm.DeliveryTag < tag {
+				// Late confirm of a publish that already timed out
+				continue
+			}
+			if !confirm.Ack {
+				return ErrPublishNacked
+			}
+			if returned == nil {
+				returned = p.drainReturns(messageID)
+			}
+			if returned != nil {
+				return &ReturnError{
+					Exchange:   returned.Exchange,
+					RoutingKey: returned.RoutingKey,
+					ReplyCode:  returned.ReplyCode,
+					ReplyText:  returned.ReplyText,
+				}
+			}
+			return nil
+		case <-timer.C:
+			return ErrPublishTimeout
+		}
+	}
+}
+
+// drainReturns reads the buffered returns without blocking and keeps the one
+// of messageID
+func (p *publisher) drainReturns(messageID string) *amqp.Return {
+	var returned *amqp.Return
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return returned
+			}
+			if ret.MessageId == messageID {
+				returned = &ret
+			}
+		default:
+			return returned
+		}
+	}
+}
+
+func (p *publisher) isClosed() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *publisher) close() error {
+	// Success
+	return p.channel.Close()
+}
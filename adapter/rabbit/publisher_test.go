@@ -0,0 +1,54 @@
+package rabbit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestPublisherReportsReturnBeforeConfirm(t *testing.T) {
+	// Both notifications are buffered before wait runs, as the client does
+	// for an unroutable mandatory message, so select sees both ready
+	for i := 0; i < 200; i++ {
+		p := &publisher{
+			confirms: make(chan amqp.Confirmation, 1),
+			returns:  make(chan amqp.Return, 1),
+		}
+		p.returns <- amqp.Return{MessageId: "id", RoutingKey: "unbound", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+		p.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		err := p.wait(1, "id", time.Second)
+		if !errors.Is(err, ErrUnroutable) {
+			t.Fatalf("iteration %d: wait returned %v, want ErrUnroutable", i, err)
+		}
+	}
+}
+
+func TestPublisherIgnoresOtherReturns(t *testing.T) {
+	p := &publisher{
+		confirms: make(chan amqp.Confirmation, 1),
+		returns:  make(chan amqp.Return, 1),
+	}
+	p.returns <- amqp.Return{MessageId: "other"}
+	p.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	if err := p.wait(1, "id", time.Second); err != nil {
+		t.Fatalf("wait returned %v, want nil", err)
+	}
+}
+
+func TestPublisherNackAndTimeout(t *testing.T) {
+	p := &publisher{
+		confirms: make(chan amqp.Confirmation, 2),
+		returns:  make(chan amqp.Return, 1),
+	}
+	p.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	p.confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	// The late confirm of tag 1 is skipped
+	if err := p.wait(2, "id", time.Second); !errors.Is(err, ErrPublishNacked) {
+		t.Fatalf("wait returned %v, want ErrPublishNacked", err)
+	}
+	if err := p.wait(3, "id", 10*time.Millisecond); !errors.Is(err, ErrPublishTimeout) {
+		t.Fatalf("wait returned %v, want ErrPublishTimeout", err)
+	}
+}
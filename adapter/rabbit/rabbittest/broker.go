@@ -0,0 +1,286 @@
+package rabbittest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	// Broker is an in-memory, deterministic rabbit.Service. Publish routes and
+	// then delivers synchronously on the calling goroutine, one message at a
+	// time, in queue declaration order. Time only moves with Advance, which
+	// expires messages and so drives TTL based retry queues.
+	Broker struct {
+		mutex     sync.Mutex
+		now       time.Time
+		exchanges map[string]*exchange
+		queues    map[string]*queue
+		order     []string
+		consumers []*consumer
+		calls     map[string]chan rabbit.Delivery
+		hooks     []rabbit.EventHook
+		tag       uint64
+		draining  bool
+		closed    bool
+	}
+
+	message struct {
+		rabbit.Message
+		exchange    string
+		routingKey  string
+		priority    uint8
+		expires     time.Time
+		redelivered bool
+	}
+)
+
+// Epoch is the broker time after New and Reset
+var Epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func New() *Broker {
+	b := &Broker{}
+	b.Reset()
+	// Success
+	return b
+}
+
+// Reset drops all topology, messages and consumers and rewinds the clock
+func (b *Broker) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, c := range b.consumers {
+		c.close()
+	}
+	b.now = Epoch
+	b.exchanges = make(map[string]*exchange)
+	b.queues = make(map[string]*queue)
+	b.order = nil
+	b.consumers = nil
+	b.calls = make(map[string]chan rabbit.Delivery)
+	b.declareDefaults()
+}
+
+func (b *Broker) Now() time.Time {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Success
+	return b.now
+}
+
+// Advance moves the broker clock, expires messages and delivers what is due
+func (b *Broker) Advance(duration clock.Duration) {
+	b.mutex.Lock()
+	b.now = b.now.Add(time.Duration(duration))
+	b.mutex.Unlock()
+	b.Drain()
+}
+
+// Emit calls the hooks registered with OnEvent, to simulate connection events
+func (b *Broker) Emit(event rabbit.Event) {
+	b.mutex.Lock()
+	hooks := append([]rabbit.EventHook{}, b.hooks...)
+	b.mutex.Unlock()
+	for _, hook := range hooks {
+		hook(event)
+	}
+}
+
+func (b *Broker) OnEvent(hook rabbit.EventHook) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.hooks = append(b.hooks, hook)
+}
+
+// Close stops every consumer with rabbit.ErrConnectionClosed, later
+// publishes and consumes fail with it
+func (b *Broker) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return rabbit.ErrConnectionClosed
+	}
+	b.closed = true
+	for _, c := range append([]*consumer{}, b.consumers...) {
+		if c.err == nil {
+			c.err = rabbit.ErrConnectionClosed
+		}
+		b.cancel(c)
+	}
+	// Success
+	return nil
+}
+
+// Len returns the number of ready messages in a queue
+func (b *Broker) Len(name string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return 0
+	}
+	b.expire(q)
+	// Success
+	return len(q.messages)
+}
+
+// Messages returns the ready messages of a queue in delivery order without
+// consuming them
+func (b *Broker) Messages(name string) []rabbit.Delivery {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return nil
+	}
+	b.expire(q)
+	result := make([]rabbit.Delivery, 0, len(q.messages))
+	for _, m := range q.messages {
+		result = append(result, m.delivery(q.name, 0))
+	}
+	// Success
+	return result
+}
+
+// Get removes and returns the next ready message of a queue, like basic.get
+// with auto ack
+func (b *Broker) Get(name string) (rabbit.Delivery, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return rabbit.Delivery{}, false
+	}
+	b.expire(q)
+	if len(q.messages) == 0 {
+		return rabbit.Delivery{}, false
+	}
+	m := q.messages[0]
+	q.messages = q.messages[1:]
+	b.tag++
+	// Success
+	return m.delivery(q.name, b.tag), true
+}
+
+func (b *Broker) Publish(exchange, key string, message rabbit.Message) error {
+	if err := b.publish(exchange, key, message); err != nil {
+		return err
+	}
+	b.Drain()
+	// Success
+	return nil
+}
+
+// PublishOnce is Publish, the broker never fails transiently
+func (b *Broker) PublishOnce(exchange, key string, message rabbit.Message) error {
+	// Success
+	return b.Publish(exchange, key, message)
+}
+
+func (b *Broker) publish(exchange, key string, message rabbit.Message) error {
+	if message.ContentType == "" {
+		message.ContentType = rabbit.MIMETextPlain
+	}
+	if message.Mode == 0 {
+		message.Mode = rabbit.Transient
+	}
+	if message.MessageID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		message.MessageID = id.String()
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return rabbit.ErrConnectionClosed
+	}
+	// Success
+	return b.route(exchange, key, message)
+}
+
+func (b *Broker) PublishJSON(exchange, key string, v interface{}) error {
+	// Success
+	return b.PublishObject(exchange, key, rabbit.Message{ContentType: rabbit.MIMEApplicationJSON}, v)
+}
+
+func (b *Broker) PublishObject(exchange, key string, message rabbit.Message, v interface{}) error {
+	if message.ContentType == "" {
+		message.ContentType = rabbit.MIMEApplicationJSON
+	}
+	codec, err := rabbit.CodecFor(message.ContentType)
+	if err != nil {
+		return err
+	}
+	if message.Body, err = codec.Marshal(v); err != nil {
+		return err
+	}
+	// Success
+	return b.Publish(exchange, key, message)
+}
+
+func (m *message) delivery(queue string, tag uint64) rabbit.Delivery {
+	d := rabbit.Delivery{
+		Body:            m.Body,
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		Mode:            m.Mode,
+		Priority:        m.Priority,
+		Headers:         copyHeaders(m.Headers),
+		CorrelationID:   m.CorrelationID,
+		ReplyTo:         m.ReplyTo,
+		MessageID:       m.MessageID,
+		Timestamp:       m.Timestamp,
+		Type:            m.Type,
+		AppID:           m.AppID,
+		Queue:           queue,
+		Exchange:        m.exchange,
+		RoutingKey:      m.routingKey,
+		Redelivered:     m.redelivered,
+		DeliveryTag:     tag,
+		Context:         context.Background(),
+	}
+	if m.Expiration > 0 {
+		d.Expiration = strconv.FormatInt(m.Expiration.Milliseconds(), 10)
+	}
+	// Success
+	return d
+}
+
+func copyHeaders(headers map[string]interface{}) map[string]interface{} {
+	if headers == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		result[key] = value
+	}
+	// Success
+	return result
+}
+
+func notFound(format string, a ...interface{}) error {
+	// Success
+	return &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - " + fmt.Sprintf(format, a...)}
+}
+
+func preconditionFailed(format string, a ...interface{}) error {
+	// Success
+	return &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - " + fmt.Sprintf(format, a...)}
+}
+
+func accessRefused(format string, a ...interface{}) error {
+	// Success
+	return &amqp.Error{Code: amqp.AccessRefused, Reason: "ACCESS_REFUSED - " + fmt.Sprintf(format, a...)}
+}
+
+var _ rabbit.Service = (*Broker)(nil)
@@ -0,0 +1,241 @@
+package rabbittest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func TestMandatoryUnroutable(t *testing.T) {
+	b := New()
+	if err := b.DeclareExchange("events", rabbit.ExchangeDirect, true); err != nil {
+		t.Fatalf("declare exchange: %v", err)
+	}
+	err := b.Publish("events", "unbound", rabbit.Message{Body: []byte("x"), Mandatory: true})
+	if !errors.Is(err, rabbit.ErrUnroutable) {
+		t.Fatalf("mandatory publish returned %v, want ErrUnroutable", err)
+	}
+	var returned *rabbit.ReturnError
+	if !errors.As(err, &returned) || returned.RoutingKey != "unbound" || returned.ReplyCode != 312 {
+		t.Fatalf("mandatory publish returned %#v", err)
+	}
+	// Without mandatory the message is dropped silently
+	if err = b.Publish("events", "unbound", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("publish returned %v, want nil", err)
+	}
+}
+
+func TestHandlerContextOutlivesStop(t *testing.T) {
+	b := New()
+	if err := b.DeclareQueue("jobs", true, 0, 0); err != nil {
+		t.Fatalf("declare queue: %v", err)
+	}
+	var sub rabbit.Subscription
+	var stopped error
+	sub, err := b.ConsumeDelivery(context.Background(), "jobs", rabbit.ConsumeOptions{}, func(delivery rabbit.Delivery) error {
+		_ = sub.Stop()
+		stopped = delivery.Context.Err()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err = b.Publish("", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if stopped != nil {
+		t.Fatalf("handler context error %v after Stop, want the handler to finish", stopped)
+	}
+	if messages := b.Messages("jobs"); len(messages) != 0 {
+		t.Fatalf("queue holds %d messages, want the in-flight one acked", len(messages))
+	}
+}
+
+func TestStopReturnsHandlerError(t *testing.T) {
+	b := New()
+	must(t, b.DeclareQueue("jobs", true, 0, 0))
+	failed := errors.New("failed")
+	sub, err := b.ConsumeDelivery(context.Background(), "jobs", rabbit.ConsumeOptions{AutoAck: true}, func(delivery rabbit.Delivery) error {
+		if string(delivery.Body) == "bad" {
+			return failed
+		}
+		return nil
+	})
+	must(t, err)
+	for _, body := range []string{"good", "bad", "other"} {
+		must(t, b.Publish("", "jobs", rabbit.Message{Body: []byte(body)}))
+	}
+	if err = sub.Stop(); err != failed {
+		t.Fatalf("stop returned %v, want the handler error", err)
+	}
+	if err = sub.Wait(); err != failed {
+		t.Fatalf("wait returned %v, want the handler error", err)
+	}
+}
+
+func TestTopicRoutingAndPriority(t *testing.T) {
+	b := New()
+	must(t, b.DeclareExchange("events", rabbit.ExchangeTopic, true))
+	must(t, b.DeclareQueueWithOptions("orders", rabbit.QueueOptions{Durable: true, Priority: 5}))
+	must(t, b.BindQueueWithOptions("orders", "events", rabbit.BindOptions{RoutingKey: "order.#"}))
+	for _, item := range []struct {
+		key      string
+		priority uint8
+	}{{"order.created", 1}, {"user.created", 9}, {"order.paid.eu", 4}} {
+		if err := b.Publish("events", item.key, rabbit.Message{Body: []byte(item.key), Priority: item.priority}); err != nil {
+			t.Fatalf("publish %s: %v", item.key, err)
+		}
+	}
+	messages := b.Messages("orders")
+	if len(messages) != 2 || messages[0].RoutingKey != "order.paid.eu" || messages[1].RoutingKey != "order.created" {
+		t.Fatalf("queue holds %+v, want the order messages by priority", messages)
+	}
+}
+
+func TestRetryTopologyDeadLetters(t *testing.T) {
+	b := New()
+	policy := rabbit.RetryPolicy{MaxAttempts: 3, InitialDelay: clock.Second}
+	must(t, b.DeclareQueue("jobs", true, 0, 0))
+	must(t, b.DeclareRetryTopology("jobs", policy))
+	calls := 0
+	_, err := b.ConsumeDelivery(context.Background(), "jobs", rabbit.ConsumeOptions{Retry: &policy}, func(delivery rabbit.Delivery) error {
+		calls++
+		return errors.New("fail")
+	})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err = b.Publish("", "jobs", rabbit.Message{Body: []byte("x")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if calls != 1 || b.Len(rabbit.RetryQueue("jobs", 1)) != 1 {
+		t.Fatalf("after the first failure calls=%d retry queue=%d", calls, b.Len(rabbit.RetryQueue("jobs", 1)))
+	}
+	// The delays double from InitialDelay
+	b.Advance(clock.Second)
+	b.Advance(2 * clock.Second)
+	if calls != 3 {
+		t.Fatalf("handler ran %d times, want 3", calls)
+	}
+	dead := b.Messages(rabbit.DeadLetterQueue("jobs"))
+	if len(dead) != 1 || dead[0].Headers[rabbit.HeaderRetryAttempts] != int32(3) || dead[0].Headers[rabbit.HeaderLastError] != "fail" {
+		t.Fatalf("dead letter queue holds %+v", dead)
+	}
+}
+
+func TestRejectDeadLettersWithoutRetry(t *testing.T) {
+	b := New()
+	must(t, b.DeclareQueue("dead", true, 0, 0))
+	must(t, b.DeclareQueueWithOptions("jobs", rabbit.QueueOptions{Durable: true, DeadLetterRoutingKey: "dead"}))
+	failures := 0
+	_, err := b.ConsumeDelivery(context.Background(), "jobs", rabbit.ConsumeOptions{}, func(delivery rabbit.Delivery) error {
+		if string(delivery.Body) == "reject" {
+			return rabbit.Reject(errors.New("bad"))
+		}
+		if !delivery.Redelivered {
+			failures++
+			return errors.New("retry me")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	must(t, b.Publish("", "jobs", rabbit.Message{Body: []byte("requeue")}))
+	b.Drain()
+	must(t, b.Publish("", "jobs", rabbit.Message{Body: []byte("reject")}))
+	if failures != 1 || b.Len("jobs") != 0 {
+		t.Fatalf("requeued message was not redelivered, failures=%d jobs=%d", failures, b.Len("jobs"))
+	}
+	dead := b.Messages("dead")
+	if len(dead) != 1 || string(dead[0].Body) != "reject" || dead[0].Headers["x-first-death-reason"] != "rejected" {
+		t.Fatalf("dead queue holds %+v", dead)
+	}
+}
+
+func TestCallServe(t *testing.T) {
+	b := New()
+	must(t, b.DeclareQueue("rpc", true, 0, 0))
+	_, err := b.Serve(context.Background(), "rpc", rabbit.ConsumeOptions{}, func(delivery rabbit.Delivery) (rabbit.Message, error) {
+		if string(delivery.Body) == "fail" {
+			return rabbit.Message{}, errors.New("remote failure")
+		}
+		return rabbit.Message{Body: append([]byte("echo "), delivery.Body...)}, nil
+	})
+	if err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	reply, err := b.Call(context.Background(), "", "rpc", rabbit.Message{Body: []byte("hi")})
+	if err != nil || string(reply.Body) != "echo hi" {
+		t.Fatalf("call returned %q, %v", reply.Body, err)
+	}
+	var remote *rabbit.RemoteError
+	if _, err = b.Call(context.Background(), "", "rpc", rabbit.Message{Body: []byte("fail")}); !errors.As(err, &remote) || remote.Reason != "remote failure" {
+		t.Fatalf("call returned %v, want a RemoteError", err)
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	b := New()
+	must(t, b.DeclareQueue("expired", true, 0, 0))
+	must(t, b.DeclareQueueWithOptions("short", rabbit.QueueOptions{Durable: true, TTL: clock.Minute, DeadLetterRoutingKey: "expired"}))
+	must(t, b.Publish("", "short", rabbit.Message{Body: []byte("x")}))
+	b.Advance(clock.Minute - clock.Second)
+	if b.Len("short") != 1 {
+		t.Fatal("message expired early")
+	}
+	b.Advance(clock.Second)
+	if b.Len("short") != 0 || b.Len("expired") != 1 {
+		t.Fatalf("after the ttl short=%d expired=%d", b.Len("short"), b.Len("expired"))
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeObjectDecodesLegacyJSON(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	b := New()
+	must(t, b.DeclareQueue("jobs", true, 0, 0))
+	var received []string
+	sub, err := b.ConsumeObject(context.Background(), "jobs", rabbit.ConsumeOptions{}, func(v item) error {
+		received = append(received, v.Name)
+		return nil
+	})
+	must(t, err)
+	defer func() { _ = sub.Stop() }()
+	// Producers encoding JSON themselves publish with the text/plain default
+	must(t, b.Publish("", "jobs", rabbit.Message{Body: []byte(`{"name":"a"}`)}))
+	if len(received) != 1 || received[0] != "a" {
+		t.Fatalf("handler received %v, want the decoded legacy message", received)
+	}
+	if messages := b.Messages(rabbit.DeadLetterQueue("jobs")); len(messages) != 0 {
+		t.Fatalf("legacy message was dead lettered")
+	}
+}
+
+func TestCloseStopsConsumers(t *testing.T) {
+	b := New()
+	must(t, b.DeclareQueue("jobs", true, 0, 0))
+	sub, err := b.ConsumeDelivery(context.Background(), "jobs", rabbit.ConsumeOptions{}, func(delivery rabbit.Delivery) error { return nil })
+	must(t, err)
+	must(t, b.Close())
+	if err = sub.Wait(); err != rabbit.ErrConnectionClosed {
+		t.Fatalf("wait returned %v, want ErrConnectionClosed", err)
+	}
+	if err = b.Publish("", "jobs", rabbit.Message{Body: []byte("x")}); err != rabbit.ErrConnectionClosed {
+		t.Fatalf("publish after close returned %v, want ErrConnectionClosed", err)
+	}
+	if err = b.Close(); err != rabbit.ErrConnectionClosed {
+		t.Fatalf("second close returned %v, want ErrConnectionClosed", err)
+	}
+}
@@ -0,0 +1,269 @@
+package rabbittest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/adapter/rabbit/internal/backoff"
+)
+
+type consumer struct {
+	broker  *Broker
+	queue   string
+	options rabbit.ConsumeOptions
+	handler rabbit.Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+	// err is the first handler error, guarded by the broker mutex
+	err error
+}
+
+func (b *Broker) Consume(name string, auto bool, prefetchCount int, callback rabbit.Consumer) error {
+	options := rabbit.ConsumeOptions{AutoAck: auto, PrefetchCount: prefetchCount}
+	sub, err := b.ConsumeContext(context.Background(), name, options, callback)
+	if err != nil {
+		return err
+	}
+	// Success
+	return sub.Wait()
+}
+
+func (b *Broker) ConsumeContext(ctx context.Context, name string, options rabbit.ConsumeOptions, callback rabbit.Consumer) (rabbit.Subscription, error) {
+	// Success
+	return b.ConsumeDelivery(ctx, name, options, func(delivery rabbit.Delivery) error {
+		return callback(delivery.Body)
+	})
+}
+
+func (b *Broker) ConsumeObject(ctx context.Context, name string, options rabbit.ConsumeOptions, handler interface{}) (rabbit.Subscription, error) {
+	h, err := rabbit.ObjectHandler(handler)
+	if err != nil {
+		return nil, err
+	}
+	// Success
+	return b.ConsumeDelivery(ctx, name, options, h)
+}
+
+// ConsumeDelivery delivers the queue backlog before returning. Concurrency
+// and prefetch are ignored, handlers always run one at a time.
+func (b *Broker) ConsumeDelivery(ctx context.Context, name string, options rabbit.ConsumeOptions, handler rabbit.Handler) (rabbit.Subscription, error) {
+	if len(options.Middlewares) > 0 {
+		handler = rabbit.Chain(options.Middlewares...)(handler)
+	}
+	c := &consumer{
+		broker:  b,
+		queue:   name,
+		options: options,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		c.cancel()
+		return nil, rabbit.ErrConnectionClosed
+	}
+	if _, ok := b.queues[name]; !ok {
+		b.mutex.Unlock()
+		c.cancel()
+		return nil, notFound("no queue '%s' in vhost '/'", name)
+	}
+	for _, current := range b.consumersOf(name) {
+		if options.Exclusive || current.options.Exclusive {
+			b.mutex.Unlock()
+			c.cancel()
+			return nil, accessRefused("queue '%s' in vhost '/' in exclusive use", name)
+		}
+	}
+	b.consumers = append(b.consumers, c)
+	b.mutex.Unlock()
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = c.Stop()
+			case <-c.done:
+			}
+		}()
+	}
+	b.Drain()
+	// Success
+	return c, nil
+}
+
+func (b *Broker) consumersOf(name string) []*consumer {
+	result := make([]*consumer, 0)
+	for _, c := range b.consumers {
+		if c.queue == name {
+			result = append(result, c)
+		}
+	}
+	// Success
+	return result
+}
+
+// cancel removes a consumer, deleting its queue when it was the last
+// consumer of an auto-delete queue
+func (b *Broker) cancel(c *consumer) {
+	for i, current := range b.consumers {
+		if current == c {
+			b.consumers = append(b.consumers[:i], b.consumers[i+1:]...)
+			break
+		}
+	}
+	c.close()
+	if q, ok := b.queues[c.queue]; ok && q.options.AutoDelete && len(b.consumersOf(c.queue)) == 0 {
+		b.removeQueue(c.queue)
+	}
+}
+
+func (c *consumer) close() {
+	c.once.Do(func() {
+		c.cancel()
+		close(c.done)
+	})
+}
+
+func (c *consumer) Stop() error {
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	c.broker.cancel(c)
+	// Success
+	return c.err
+}
+
+func (c *consumer) Done() <-chan struct{} {
+	// Success
+	return c.done
+}
+
+func (c *consumer) Wait() error {
+	<-c.done
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+	// Success
+	return c.err
+}
+
+// Drain delivers ready messages to consumers until none is left. Every
+// message is delivered at most once per call, so a requeued message waits
+// for the next Publish, Advance or Drain. Nested calls from handlers return
+// immediately, the outer call picks up what they published.
+func (b *Broker) Drain() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.draining {
+		return
+	}
+	b.draining = true
+	defer func() { b.draining = false }()
+	seen := make(map[*message]bool)
+	for {
+		q, m, c := b.next(seen)
+		if m == nil {
+			return
+		}
+		seen[m] = true
+		b.tag++
+		var err error
+		func() {
+			// Handlers may publish or stop consumers, and a panic must leave
+			// the mutex locked for the deferred unlock
+			b.mutex.Unlock()
+			defer b.mutex.Lock()
+			delivery := m.delivery(q.name, b.tag)
+			delivery.Context = context.Background()
+			err = c.handler(delivery)
+		}()
+		if err != nil && c.err == nil {
+			c.err = err
+		}
+		b.settle(q, m, c, err)
+	}
+}
+
+// next picks the first ready message of the first queue, in declaration
+// order, with a consumer, rotating between consumers of the same queue
+func (b *Broker) next(seen map[*message]bool) (*queue, *message, *consumer) {
+	// Expiring may dead letter into any queue, so expire all of them first
+	for _, name := range append([]string{}, b.order...) {
+		if q, ok := b.queues[name]; ok {
+			b.expire(q)
+		}
+	}
+	for _, name := range b.order {
+		q := b.queues[name]
+		consumers := b.consumersOf(name)
+		if len(consumers) == 0 {
+			continue
+		}
+		for i, m := range q.messages {
+			if seen[m] {
+				continue
+			}
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			c := consumers[q.next%len(consumers)]
+			q.next++
+			return q, m, c
+		}
+	}
+	// Success
+	return nil, nil, nil
+}
+
+// settle mirrors the rabbit consumer: ack on success, retry or dead letter
+// through the retry policy, otherwise requeue unless the error rejects
+func (b *Broker) settle(q *queue, m *message, c *consumer, err error) {
+	if c.options.AutoAck || err == nil {
+		return
+	}
+	permanent := errors.Is(err, rabbit.ErrReject)
+	if c.options.Retry != nil && b.retry(q, m, c.options.Retry, err, permanent) == nil {
+		return
+	}
+	if !permanent || c.options.Retry != nil {
+		m.redelivered = true
+		q.insert(m, true)
+		return
+	}
+	b.deadLetter(q, m, reasonRejected)
+}
+
+func (b *Broker) retry(q *queue, m *message, policy *rabbit.RetryPolicy, reason error, permanent bool) error {
+	failures := retryAttempts(m.Headers) + 1
+	key := rabbit.DeadLetterQueue(q.name)
+	if !permanent && failures < backoff.Policy(*policy).Attempts() {
+		key = rabbit.RetryQueue(q.name, failures)
+	}
+	msg := m.Message
+	msg.Headers = copyHeaders(m.Headers)
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]interface{})
+	}
+	msg.Headers[rabbit.HeaderRetryAttempts] = int32(failures)
+	msg.Headers[rabbit.HeaderLastError] = reason.Error()
+	msg.Expiration = 0
+	msg.Mandatory = true
+	// Success
+	return b.route("", key, msg)
+}
+
+func retryAttempts(headers map[string]interface{}) int {
+	switch v := headers[rabbit.HeaderRetryAttempts].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	// Success
+	return 0
+}
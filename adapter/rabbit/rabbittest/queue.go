@@ -0,0 +1,204 @@
+package rabbittest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+)
+
+const (
+	reasonExpired  = "expired"
+	reasonRejected = "rejected"
+	reasonMaxLen   = "maxlen"
+)
+
+// route delivers a message to every queue bound directly or through exchange
+// bindings, each queue receiving its own copy
+func (b *Broker) route(name, key string, msg rabbit.Message) error {
+	ex, ok := b.exchanges[name]
+	if !ok {
+		return notFound("no exchange '%s' in vhost '/'", name)
+	}
+	if ex.options.Internal {
+		return accessRefused("cannot publish to internal exchange '%s' in vhost '/'", name)
+	}
+	if name == "" && strings.HasPrefix(key, rabbit.DirectReplyTo+".") {
+		return b.reply(key, msg)
+	}
+	targets := make([]string, 0)
+	if name == "" {
+		if _, ok := b.queues[key]; ok {
+			targets = append(targets, key)
+		}
+	} else {
+		b.resolve(ex, key, msg.Headers, map[string]bool{}, &targets)
+	}
+	if len(targets) == 0 {
+		if msg.Mandatory {
+			return &rabbit.ReturnError{Exchange: name, RoutingKey: key, ReplyCode: 312, ReplyText: "NO_ROUTE"}
+		}
+		return nil
+	}
+	var result error
+	for _, target := range targets {
+		m := &message{Message: msg, exchange: name, routingKey: key}
+		m.Headers = copyHeaders(msg.Headers)
+		if err := b.enqueue(b.queues[target], m); err != nil && result == nil {
+			result = err
+		}
+	}
+	// Success
+	return result
+}
+
+func (b *Broker) resolve(ex *exchange, key string, headers map[string]interface{}, visited map[string]bool, targets *[]string) {
+	if visited[ex.name] {
+		return
+	}
+	visited[ex.name] = true
+	for _, item := range ex.bindings {
+		if !item.matches(ex.kind, key, headers) {
+			continue
+		}
+		if item.toExchange {
+			if next, ok := b.exchanges[item.destination]; ok {
+				b.resolve(next, key, headers, visited, targets)
+			}
+			continue
+		}
+		if contains(*targets, item.destination) {
+			continue
+		}
+		*targets = append(*targets, item.destination)
+	}
+}
+
+func (b *Broker) enqueue(q *queue, m *message) error {
+	if q.options.Priority > 0 {
+		m.priority = m.Priority
+		if int(m.priority) > q.options.Priority {
+			m.priority = uint8(q.options.Priority)
+		}
+	}
+	var ttl time.Duration
+	if q.options.TTL > 0 {
+		ttl = time.Duration(q.options.TTL)
+	}
+	if m.Expiration > 0 && (ttl == 0 || time.Duration(m.Expiration) < ttl) {
+		ttl = time.Duration(m.Expiration)
+	}
+	if ttl > 0 {
+		m.expires = b.now.Add(ttl)
+	}
+	overflow := q.options.Overflow == rabbit.OverflowRejectPublish || q.options.Overflow == rabbit.OverflowRejectPublishDLX
+	if overflow && q.full(m) {
+		if q.options.Overflow == rabbit.OverflowRejectPublishDLX {
+			b.deadLetter(q, m, reasonMaxLen)
+		}
+		return rabbit.ErrPublishNacked
+	}
+	q.insert(m, false)
+	for !overflow && q.over() {
+		head := q.messages[0]
+		q.messages = q.messages[1:]
+		b.deadLetter(q, head, reasonMaxLen)
+	}
+	// Success
+	return nil
+}
+
+// insert keeps messages ordered by priority, FIFO within a priority. A
+// requeued message goes back to the head of its priority.
+func (q *queue) insert(m *message, requeue bool) {
+	i := 0
+	for ; i < len(q.messages); i++ {
+		current := q.messages[i].priority
+		if current < m.priority || (requeue && current == m.priority) {
+			break
+		}
+	}
+	q.messages = append(q.messages, nil)
+	copy(q.messages[i+1:], q.messages[i:])
+	q.messages[i] = m
+}
+
+func (q *queue) full(m *message) bool {
+	if q.options.MaxLength > 0 && len(q.messages)+1 > q.options.MaxLength {
+		return true
+	}
+	// Success
+	return q.options.MaxLengthBytes > 0 && q.bytes()+len(m.Body) > q.options.MaxLengthBytes
+}
+
+func (q *queue) over() bool {
+	if len(q.messages) == 0 {
+		return false
+	}
+	if q.options.MaxLength > 0 && len(q.messages) > q.options.MaxLength {
+		return true
+	}
+	// Success
+	return q.options.MaxLengthBytes > 0 && q.bytes() > q.options.MaxLengthBytes
+}
+
+func (q *queue) bytes() int {
+	total := 0
+	for _, m := range q.messages {
+		total += len(m.Body)
+	}
+	// Success
+	return total
+}
+
+// expire dead letters every message past its TTL
+func (b *Broker) expire(q *queue) {
+	messages := q.messages[:0]
+	expired := make([]*message, 0)
+	for _, m := range q.messages {
+		if !m.expires.IsZero() && !m.expires.After(b.now) {
+			expired = append(expired, m)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	q.messages = messages
+	for _, m := range expired {
+		b.deadLetter(q, m, reasonExpired)
+	}
+}
+
+// deadLetter republishes a message to the queue dead letter exchange, or
+// drops it when the queue has none
+func (b *Broker) deadLetter(q *queue, m *message, reason string) {
+	if q.options.DeadLetterExchange == "" && q.options.DeadLetterRoutingKey == "" {
+		return
+	}
+	key := q.options.DeadLetterRoutingKey
+	if key == "" {
+		key = m.routingKey
+	}
+	msg := m.Message
+	msg.Headers = copyHeaders(m.Headers)
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]interface{})
+	}
+	if _, ok := msg.Headers["x-first-death-reason"]; !ok {
+		msg.Headers["x-first-death-reason"] = reason
+		msg.Headers["x-first-death-queue"] = q.name
+		msg.Headers["x-first-death-exchange"] = m.exchange
+	}
+	msg.Expiration = 0
+	msg.Mandatory = false
+	_ = b.route(q.options.DeadLetterExchange, key, msg)
+}
+
+func contains(items []string, item string) bool {
+	for _, current := range items {
+		if current == item {
+			return true
+		}
+	}
+	// Success
+	return false
+}
@@ -0,0 +1,80 @@
+package rabbittest
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+)
+
+// Call works like the rabbit client with direct reply-to. A Serve consumer
+// on the broker answers before Publish returns, so the reply is usually
+// immediate. Calls made from inside a handler cannot be served and time out.
+func (b *Broker) Call(ctx context.Context, exchange, routingKey string, message rabbit.Message) (rabbit.Delivery, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(rabbit.DefaultCallTimeout))
+		defer cancel()
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return rabbit.Delivery{}, err
+	}
+	message.CorrelationID = id.String()
+	// The broker rewrites the pseudo queue into a per channel address
+	message.ReplyTo = rabbit.DirectReplyTo + "." + id.String()
+	reply := make(chan rabbit.Delivery, 1)
+	b.mutex.Lock()
+	b.calls[message.ReplyTo] = reply
+	b.mutex.Unlock()
+	defer func() {
+		b.mutex.Lock()
+		delete(b.calls, message.ReplyTo)
+		b.mutex.Unlock()
+	}()
+	if err = b.Publish(exchange, routingKey, message); err != nil {
+		return rabbit.Delivery{}, err
+	}
+	select {
+	case delivery := <-reply:
+		if reason, ok := delivery.Headers[rabbit.HeaderRPCError].(string); ok {
+			return delivery, &rabbit.RemoteError{Reason: reason}
+		}
+		return delivery, nil
+	case <-ctx.Done():
+		return rabbit.Delivery{}, ctx.Err()
+	}
+}
+
+func (b *Broker) reply(key string, msg rabbit.Message) error {
+	reply, ok := b.calls[key]
+	if !ok {
+		if msg.Mandatory {
+			return &rabbit.ReturnError{RoutingKey: key, ReplyCode: 312, ReplyText: "NO_ROUTE"}
+		}
+		return nil
+	}
+	delete(b.calls, key)
+	m := &message{Message: msg, routingKey: key}
+	reply <- m.delivery(rabbit.DirectReplyTo, 0)
+	// Success
+	return nil
+}
+
+func (b *Broker) Serve(ctx context.Context, name string, options rabbit.ConsumeOptions, handler rabbit.ReplyHandler) (rabbit.Subscription, error) {
+	// Success
+	return b.ConsumeDelivery(ctx, name, options, func(delivery rabbit.Delivery) error {
+		response, err := handler(delivery)
+		if delivery.ReplyTo == "" {
+			return err
+		}
+		if err != nil {
+			response = rabbit.Message{Headers: map[string]interface{}{rabbit.HeaderRPCError: err.Error()}}
+		}
+		response.CorrelationID = delivery.CorrelationID
+		response.ReplyTo = ""
+		return b.publish("", delivery.ReplyTo, response)
+	})
+}
@@ -0,0 +1,333 @@
+package rabbittest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit"
+	"github.com/h14yhv/golang-lib/adapter/rabbit/internal/backoff"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	exchange struct {
+		name     string
+		kind     string
+		options  rabbit.ExchangeOptions
+		bindings []*binding
+	}
+
+	binding struct {
+		destination string
+		toExchange  bool
+		key         string
+		arguments   map[string]interface{}
+	}
+
+	queue struct {
+		name     string
+		options  rabbit.QueueOptions
+		messages []*message
+		next     int
+	}
+)
+
+var kinds = map[string]bool{
+	rabbit.ExchangeDirect:  true,
+	rabbit.ExchangeFanout:  true,
+	rabbit.ExchangeTopic:   true,
+	rabbit.ExchangeHeaders: true,
+}
+
+func (b *Broker) declareDefaults() {
+	b.exchanges[""] = &exchange{kind: rabbit.ExchangeDirect, options: rabbit.ExchangeOptions{Durable: true}}
+	for kind := range kinds {
+		name := "amq." + kind
+		b.exchanges[name] = &exchange{name: name, kind: kind, options: rabbit.ExchangeOptions{Durable: true}}
+	}
+}
+
+func (b *Broker) DeclareExchange(name, kind string, durable bool) error {
+	// Success
+	return b.DeclareExchangeWithOptions(name, kind, rabbit.ExchangeOptions{Durable: durable})
+}
+
+func (b *Broker) DeclareExchangeWithOptions(name, kind string, options rabbit.ExchangeOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if current, ok := b.exchanges[name]; ok {
+		if current.kind != kind || current.options.Durable != options.Durable ||
+			current.options.AutoDelete != options.AutoDelete || current.options.Internal != options.Internal {
+			return preconditionFailed("inequivalent arg for exchange '%s' in vhost '/'", name)
+		}
+		return nil
+	}
+	if name == "" || strings.HasPrefix(name, "amq.") {
+		return accessRefused("exchange name '%s' contains reserved prefix 'amq.*'", name)
+	}
+	if !kinds[kind] {
+		return &amqp.Error{Code: amqp.CommandInvalid, Reason: fmt.Sprintf("COMMAND_INVALID - unknown exchange type '%s'", kind)}
+	}
+	b.exchanges[name] = &exchange{name: name, kind: kind, options: options}
+	// Success
+	return nil
+}
+
+func (b *Broker) DeclareQueue(name string, durable bool, priority int, ttl clock.Duration) error {
+	// Success
+	return b.DeclareQueueWithOptions(name, rabbit.QueueOptions{Durable: durable, Priority: priority, TTL: ttl})
+}
+
+func (b *Broker) DeclareQueueWithOptions(name string, options rabbit.QueueOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if name == "" {
+		return preconditionFailed("server named queues are not supported")
+	}
+	if current, ok := b.queues[name]; ok {
+		if !reflect.DeepEqual(current.options, options) {
+			return preconditionFailed("inequivalent arg for queue '%s' in vhost '/'", name)
+		}
+		return nil
+	}
+	if strings.HasPrefix(name, "amq.") {
+		return accessRefused("queue name '%s' contains reserved prefix 'amq.*'", name)
+	}
+	b.queues[name] = &queue{name: name, options: options}
+	b.order = append(b.order, name)
+	// Success
+	return nil
+}
+
+func (b *Broker) DeclareRetryTopology(name string, policy rabbit.RetryPolicy) error {
+	delays := backoff.Policy(policy)
+	for retry := 1; retry < delays.Attempts(); retry++ {
+		options := rabbit.QueueOptions{
+			Durable:              true,
+			TTL:                  delays.Delay(retry),
+			DeadLetterRoutingKey: name,
+		}
+		if err := b.DeclareQueueWithOptions(rabbit.RetryQueue(name, retry), options); err != nil {
+			return err
+		}
+	}
+	// Success
+	return b.DeclareQueueWithOptions(rabbit.DeadLetterQueue(name), rabbit.QueueOptions{Durable: true})
+}
+
+func (b *Broker) BindQueue(name, exchange string) error {
+	// Success
+	return b.BindQueueWithOptions(name, exchange, rabbit.BindOptions{RoutingKey: name})
+}
+
+func (b *Broker) BindQueueWithOptions(name, exchange string, options rabbit.BindOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.queues[name]; !ok {
+		return notFound("no queue '%s' in vhost '/'", name)
+	}
+	// Success
+	return b.bind(exchange, &binding{destination: name, key: options.RoutingKey, arguments: options.Arguments})
+}
+
+func (b *Broker) UnbindQueue(name, exchange string, options rabbit.BindOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Success
+	return b.unbind(exchange, &binding{destination: name, key: options.RoutingKey, arguments: options.Arguments})
+}
+
+func (b *Broker) BindExchange(destination, source string, options rabbit.BindOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.exchanges[destination]; !ok {
+		return notFound("no exchange '%s' in vhost '/'", destination)
+	}
+	// Success
+	return b.bind(source, &binding{destination: destination, toExchange: true, key: options.RoutingKey, arguments: options.Arguments})
+}
+
+func (b *Broker) UnbindExchange(destination, source string, options rabbit.BindOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	// Success
+	return b.unbind(source, &binding{destination: destination, toExchange: true, key: options.RoutingKey, arguments: options.Arguments})
+}
+
+func (b *Broker) bind(name string, target *binding) error {
+	ex, ok := b.exchanges[name]
+	if !ok {
+		return notFound("no exchange '%s' in vhost '/'", name)
+	}
+	if name == "" {
+		return accessRefused("operation not permitted on the default exchange")
+	}
+	for _, current := range ex.bindings {
+		if current.equal(target) {
+			return nil
+		}
+	}
+	ex.bindings = append(ex.bindings, target)
+	// Success
+	return nil
+}
+
+func (b *Broker) unbind(name string, target *binding) error {
+	ex, ok := b.exchanges[name]
+	if !ok {
+		return nil
+	}
+	for i, current := range ex.bindings {
+		if current.equal(target) {
+			ex.bindings = append(ex.bindings[:i], ex.bindings[i+1:]...)
+			break
+		}
+	}
+	b.autoDelete(ex)
+	// Success
+	return nil
+}
+
+func (b *Broker) autoDelete(ex *exchange) {
+	if ex.options.AutoDelete && len(ex.bindings) == 0 {
+		b.removeExchange(ex.name)
+	}
+}
+
+func (b *Broker) DeleteExchange(name string, ifUnused bool) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	ex, ok := b.exchanges[name]
+	if !ok {
+		return nil
+	}
+	if name == "" || strings.HasPrefix(name, "amq.") {
+		return accessRefused("operation not permitted on exchange '%s'", name)
+	}
+	if ifUnused && len(ex.bindings) > 0 {
+		return preconditionFailed("exchange '%s' in vhost '/' in use", name)
+	}
+	b.removeExchange(name)
+	// Success
+	return nil
+}
+
+func (b *Broker) removeExchange(name string) {
+	delete(b.exchanges, name)
+	for _, ex := range b.exchanges {
+		b.removeBindings(ex, func(item *binding) bool { return item.toExchange && item.destination == name })
+	}
+}
+
+// DeleteQueue also cancels the consumers of the queue, their subscriptions
+// end as they would on a real broker
+func (b *Broker) DeleteQueue(name string, ifUnused, ifEmpty bool) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		return 0, nil
+	}
+	if ifUnused && len(b.consumersOf(name)) > 0 {
+		return 0, preconditionFailed("queue '%s' in vhost '/' in use", name)
+	}
+	b.expire(q)
+	if ifEmpty && len(q.messages) > 0 {
+		return 0, preconditionFailed("queue '%s' in vhost '/' not empty", name)
+	}
+	for _, c := range b.consumersOf(name) {
+		b.cancel(c)
+	}
+	b.removeQueue(name)
+	// Success
+	return len(q.messages), nil
+}
+
+func (b *Broker) removeQueue(name string) {
+	delete(b.queues, name)
+	for i, current := range b.order {
+		if current == name {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+	for _, ex := range b.exchanges {
+		b.removeBindings(ex, func(item *binding) bool { return !item.toExchange && item.destination == name })
+	}
+}
+
+func (b *Broker) removeBindings(ex *exchange, match func(item *binding) bool) {
+	if len(ex.bindings) == 0 {
+		return
+	}
+	bindings := ex.bindings[:0]
+	for _, item := range ex.bindings {
+		if !match(item) {
+			bindings = append(bindings, item)
+		}
+	}
+	ex.bindings = bindings
+	b.autoDelete(ex)
+}
+
+func (item *binding) equal(other *binding) bool {
+	// Success
+	return item.destination == other.destination && item.toExchange == other.toExchange &&
+		item.key == other.key && reflect.DeepEqual(item.arguments, other.arguments)
+}
+
+func (item *binding) matches(kind, key string, headers map[string]interface{}) bool {
+	switch kind {
+	case rabbit.ExchangeFanout:
+		return true
+	case rabbit.ExchangeTopic:
+		return matchTopic(strings.Split(item.key, "."), strings.Split(key, "."))
+	case rabbit.ExchangeHeaders:
+		return matchHeaders(item.arguments, headers)
+	}
+	// Success
+	return item.key == key
+}
+
+// matchTopic matches routing key words against a binding pattern, where *
+// is exactly one word and # is zero or more words
+func matchTopic(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	if pattern[0] == "#" {
+		for i := 0; i <= len(words); i++ {
+			if matchTopic(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(words) == 0 || (pattern[0] != "*" && pattern[0] != words[0]) {
+		return false
+	}
+	// Success
+	return matchTopic(pattern[1:], words[1:])
+}
+
+func matchHeaders(arguments, headers map[string]interface{}) bool {
+	any := arguments["x-match"] == "any"
+	matched, total := 0, 0
+	for key, value := range arguments {
+		if strings.HasPrefix(key, "x-") {
+			continue
+		}
+		total++
+		if current, ok := headers[key]; ok && fmt.Sprint(current) == fmt.Sprint(value) {
+			matched++
+		}
+	}
+	if any {
+		return matched > 0
+	}
+	// Success
+	return matched == total
+}
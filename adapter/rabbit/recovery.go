@@ -0,0 +1,155 @@
+package rabbit
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	stageExchange = iota
+	stageQueue
+	stageExchangeBinding
+	stageQueueBinding
+)
+
+type (
+	// topology remembers successful declarations so they can be replayed on
+	// a new connection, later declarations of the same key replace earlier ones
+	topology struct {
+		mutex   sync.Mutex
+		entries []*declaration
+	}
+
+	declaration struct {
+		key         string
+		stage       int
+		source      string
+		destination string
+		apply       func(channel *amqp.Channel) error
+	}
+)
+
+func newTopology() *topology {
+	// Success
+	return &topology{}
+}
+
+func (t *topology) record(entry *declaration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, current := range t.entries {
+		if current.key == entry.key {
+			t.entries[i] = entry
+			return
+		}
+	}
+	t.entries = append(t.entries, entry)
+}
+
+func (t *topology) remove(match func(entry *declaration) bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entries := t.entries[:0]
+	for _, entry := range t.entries {
+		if !match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	t.entries = entries
+}
+
+// replay declares exchanges, queues and then bindings on connection. Every
+// entry is attempted, the first failure is returned.
+func (t *topology) replay(connection *amqp.Connection) error {
+	t.mutex.Lock()
+	entries := append([]*declaration{}, t.entries...)
+	t.mutex.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].stage < entries[j].stage })
+	var result error
+	for _, entry := range entries {
+		if err := withChannel(connection, entry.apply); err != nil && result == nil {
+			result = err
+		}
+	}
+	// Success
+	return result
+}
+
+func (r *rabbitConnection) declare(entry *declaration) error {
+	if err := r.channel(entry.apply); err != nil {
+		return err
+	}
+	r.topology.record(entry)
+	// Success
+	return nil
+}
+
+func (r *rabbitConnection) OnEvent(hook EventHook) {
+	r.hookMutex.Lock()
+	defer r.hookMutex.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *rabbitConnection) emit(event Event) {
+	r.hookMutex.RLock()
+	defer r.hookMutex.RUnlock()
+	for _, hook := range r.hooks {
+		hook(event)
+	}
+}
+
+func (r *rabbitConnection) monitor(connection *amqp.Connection) {
+	defer close(r.monitored)
+	for {
+		event := Event{Type: EventConnectionLost}
+		reason := <-connection.NotifyClose(make(chan *amqp.Error, 1))
+		if r.ctx.Err() != nil {
+			// Closed by Close
+			return
+		}
+		if reason != nil {
+			event.Err = reason
+			r.logger.Infof("connection closed, reason: %v", reason)
+		} else {
+			r.logger.Info("connection closed")
+		}
+		r.emit(event)
+		if connection = r.reconnect(); connection == nil {
+			return
+		}
+		r.mutex.Lock()
+		if r.ctx.Err() != nil {
+			// Close ran while the connection was recreated
+			r.mutex.Unlock()
+			_ = connection.Close()
+			return
+		}
+		r.connection = connection
+		r.mutex.Unlock()
+		r.logger.Info("recreate connection success!")
+		r.emit(Event{Type: EventConnectionRestored})
+	}
+}
+
+// reconnect dials with backoff and replays the recorded topology before the
+// connection is handed out, so recovering consumers find their queues. It
+// returns nil once Close was called.
+func (r *rabbitConnection) reconnect() *amqp.Connection {
+	for attempt := 0; ; attempt++ {
+		if !sleep(r.ctx, r.config.Reconnect.backoff(attempt)) {
+			return nil
+		}
+		connection, err := r.dial()
+		if err != nil {
+			r.logger.Errorf("recreate connection failed, reason: %v", err)
+			continue
+		}
+		if err = r.topology.replay(connection); err != nil {
+			r.logger.Errorf("recreate topology failed, reason: %v", err)
+			r.emit(Event{Type: EventTopologyFailed, Err: err})
+		}
+		return connection
+	}
+}
@@ -0,0 +1,121 @@
+package rabbit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/adapter/rabbit/internal/backoff"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func (p *RetryPolicy) backoff() backoff.Policy {
+	// Success
+	return backoff.Policy(*p)
+}
+
+func (p *RetryPolicy) attempts() int {
+	// Success
+	return p.backoff().Attempts()
+}
+
+// delay returns the wait before the given retry, starting from 1
+func (p *RetryPolicy) delay(retry int) clock.Duration {
+	// Success
+	return p.backoff().Delay(retry)
+}
+
+func RetryQueue(queue string, retry int) string {
+	// Success
+	return fmt.Sprintf("%s%s.%d", queue, SuffixRetry, retry)
+}
+
+func DeadLetterQueue(queue string) string {
+	// Success
+	return queue + SuffixDeadLetter
+}
+
+// DeclareRetryTopology declares one delay queue per retry and the final dead
+// letter queue. Delay queues hold messages for their TTL, then dead letter
+// them through the default exchange back to the work queue.
+func (r *rabbitConnection) DeclareRetryTopology(queue string, policy RetryPolicy) error {
+	for retry := 1; retry < policy.attempts(); retry++ {
+		options := QueueOptions{
+			Durable:              true,
+			TTL:                  policy.delay(retry),
+			DeadLetterRoutingKey: queue,
+		}
+		if err := r.DeclareQueueWithOptions(RetryQueue(queue, retry), options); err != nil {
+			return err
+		}
+	}
+	if err := r.DeclareQueueWithOptions(DeadLetterQueue(queue), QueueOptions{Durable: true}); err != nil {
+		return err
+	}
+	// Success
+	return nil
+}
+
+// retry republishes a failed delivery to the next delay queue, or to the dead
+// letter queue once attempts are exhausted or the failure is permanent. The
+// original is acked only after the broker confirmed the copy.
+func (c *consumer) retry(msg amqp.Delivery, reason error, permanent bool) error {
+	failures := retryAttempts(msg.Headers) + 1
+	key := DeadLetterQueue(c.queue)
+	if !permanent && failures < c.options.Retry.attempts() {
+		key = RetryQueue(c.queue, failures)
+	}
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderRetryAttempts] = int32(failures)
+	headers[HeaderLastError] = reason.Error()
+	publishing := amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+	if publishing.MessageId == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return err
+		}
+		publishing.MessageId = id.String()
+	}
+	// A single attempt, a failure requeues the delivery instead of blocking
+	// the worker through the publish retries
+	timeout := time.Duration(c.service.config.Publish.timeout())
+	if err := c.service.publish("", key, true, publishing, timeout); err != nil {
+		return err
+	}
+	// Success
+	return msg.Ack(false)
+}
+
+func retryAttempts(headers amqp.Table) int {
+	switch v := headers[HeaderRetryAttempts].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	// Success
+	return 0
+}
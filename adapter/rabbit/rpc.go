@@ -0,0 +1,196 @@
+package rabbit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	rpcClient struct {
+		mutex   sync.Mutex
+		channel *amqp.Channel
+		pending map[string]chan rpcResult
+		closed  chan struct{}
+	}
+
+	rpcResult struct {
+		delivery Delivery
+		err      error
+	}
+)
+
+// newRPCClient opens a channel consuming the direct reply-to pseudo queue.
+// Requests must be published on this same channel for replies to arrive.
+func newRPCClient(connection *amqp.Connection) (*rpcClient, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := channel.Consume(DirectReplyTo, "", true, false, false, false, nil)
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	c := &rpcClient{
+		channel: channel,
+		pending: make(map[string]chan rpcResult),
+		closed:  make(chan struct{}),
+	}
+	returns := channel.NotifyReturn(make(chan amqp.Return, 16))
+	go c.dispatch(replies, returns)
+	// Success
+	return c, nil
+}
+
+func (c *rpcClient) dispatch(replies <-chan amqp.Delivery, returns <-chan amqp.Return) {
+	for replies != nil || returns != nil {
+		select {
+		case msg, ok := <-replies:
+			if !ok {
+				replies = nil
+				continue
+			}
+			c.resolve(msg.CorrelationId, rpcResult{delivery: newDelivery(DirectReplyTo, msg)})
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.resolve(ret.CorrelationId, rpcResult{err: &ReturnError{
+				Exchange:   ret.Exchange,
+				RoutingKey: ret.RoutingKey,
+				ReplyCode:  ret.ReplyCode,
+				ReplyText:  ret.ReplyText,
+			}})
+		}
+	}
+	close(c.closed)
+}
+
+func (c *rpcClient) resolve(id string, result rpcResult) {
+	c.mutex.Lock()
+	reply, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mutex.Unlock()
+	if ok {
+		reply <- result
+	}
+}
+
+func (c *rpcClient) call(ctx context.Context, exchange, key string, mandatory bool, msg amqp.Publishing) (Delivery, error) {
+	reply := make(chan rpcResult, 1)
+	c.mutex.Lock()
+	c.pending[msg.CorrelationId] = reply
+	err := c.channel.Publish(exchange, key, mandatory, false, msg)
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, msg.CorrelationId)
+		c.mutex.Unlock()
+	}()
+	if err != nil {
+		return Delivery{}, err
+	}
+	select {
+	case result := <-reply:
+		if result.err != nil {
+			return Delivery{}, result.err
+		}
+		if reason, ok := result.delivery.Headers[HeaderRPCError].(string); ok {
+			return result.delivery, &RemoteError{Reason: reason}
+		}
+		return result.delivery, nil
+	case <-c.closed:
+		return Delivery{}, ErrChannelClosed
+	case <-ctx.Done():
+		return Delivery{}, ctx.Err()
+	}
+}
+
+func (c *rpcClient) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *rabbitConnection) rpc() (*rpcClient, error) {
+	r.rpcMutex.Lock()
+	defer r.rpcMutex.Unlock()
+	if r.rpcClient != nil && !r.rpcClient.isClosed() {
+		return r.rpcClient, nil
+	}
+	connection, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newRPCClient(connection)
+	if err != nil {
+		return nil, err
+	}
+	r.rpcClient = c
+	// Success
+	return c, nil
+}
+
+// Call publishes a request and waits for the correlated reply. Without a
+// deadline on ctx the call is bounded by DefaultCallTimeout, which is also
+// used as the request expiration so stale requests are not served.
+func (r *rabbitConnection) Call(ctx context.Context, exchange, routingKey string, message Message) (Delivery, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(DefaultCallTimeout))
+		defer cancel()
+	}
+	if message.Expiration <= 0 {
+		deadline, _ := ctx.Deadline()
+		message.Expiration = clock.Duration(time.Until(deadline))
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return Delivery{}, err
+	}
+	message.CorrelationID = id.String()
+	message.ReplyTo = DirectReplyTo
+	publishing, err := newPublishing(message)
+	if err != nil {
+		return Delivery{}, err
+	}
+	client, err := r.rpc()
+	if err != nil {
+		return Delivery{}, err
+	}
+	// Success
+	return client.call(ctx, exchange, routingKey, message.Mandatory, publishing)
+}
+
+// Serve consumes requests from queue and publishes the handler response to
+// the request ReplyTo. Handler errors are sent back to the caller and the
+// request is acked, only a failure to reply leaves it for redelivery.
+func (r *rabbitConnection) Serve(ctx context.Context, queue string, options ConsumeOptions, handler ReplyHandler) (Subscription, error) {
+	// Success
+	return r.ConsumeDelivery(ctx, queue, options, func(delivery Delivery) error {
+		response, err := handler(delivery)
+		if delivery.ReplyTo == "" {
+			return err
+		}
+		if err != nil {
+			response = Message{Headers: map[string]interface{}{HeaderRPCError: err.Error()}}
+		}
+		response.CorrelationID = delivery.CorrelationID
+		response.ReplyTo = ""
+		publishing, err := newPublishing(response)
+		if err != nil {
+			return err
+		}
+		return r.send("", delivery.ReplyTo, false, publishing)
+	})
+}
@@ -1,8 +1,13 @@
 package rabbit
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/streadway/amqp"
@@ -12,220 +17,267 @@ import (
 )
 
 type rabbitConnection struct {
+	// ctx is cancelled by Close, it stops the monitor and the consumers
+	ctx        context.Context
+	cancel     context.CancelFunc
+	monitored  chan struct{}
+	consumers  sync.WaitGroup
 	logger     log.Logger
+	mutex      sync.RWMutex
 	connection *amqp.Connection
-	channel    *amqp.Channel
+	publishers *pool
+	topology   *topology
+	hookMutex  sync.RWMutex
+	hooks      []EventHook
+	rpcMutex   sync.Mutex
+	rpcClient  *rpcClient
 	config     Config
-	tlsConfig  *tls.Config
-	uuid       string
+	options    options
 }
 
+// NewService panics when the broker is unreachable, use New to handle the
+// error instead
 func NewService(conf Config, tlsConf *tls.Config) Service {
-	logger, _ := log.New(Module, log.DebugLevel, true, os.Stdout)
-	rb := &rabbitConnection{
-		logger:    logger,
-		config:    conf,
-		tlsConfig: tlsConf,
-	}
-	if err := rb.newConnection(); err != nil {
-		panic(err)
-	}
-	if err := rb.newChannel(); err != nil {
+	rb, err := New(conf, WithTLS(tlsConf))
+	if err != nil {
 		panic(err)
 	}
-	// Monitor
-	rb.monitor()
 	// Success
 	return rb
 }
 
-func (r *rabbitConnection) newConnection() error {
-	var err error
-	if r.config.Secure || r.tlsConfig != nil {
-		if r.tlsConfig == nil {
-			r.tlsConfig = &tls.Config{
-				InsecureSkipVerify: true,
-			}
+func New(conf Config, opts ...Option) (Service, error) {
+	rb := &rabbitConnection{
+		config:    conf,
+		options:   defaultOptions(),
+		topology:  newTopology(),
+		monitored: make(chan struct{}),
+	}
+	rb.ctx, rb.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(&rb.options)
+	}
+	if rb.options.err != nil {
+		rb.cancel()
+		return nil, rb.options.err
+	}
+	rb.logger = rb.options.logger
+	if rb.logger == nil {
+		logger, err := log.New(Module, log.DebugLevel, true, os.Stdout)
+		if err != nil {
+			rb.cancel()
+			return nil, err
 		}
-		r.connection, err = amqp.DialTLS(r.config.String(), r.tlsConfig)
-	} else {
-		r.connection, err = amqp.Dial(r.config.String())
+		rb.logger = logger
 	}
+	connection, err := rb.connect()
 	if err != nil {
-		return err
+		rb.cancel()
+		return nil, err
+	}
+	rb.connection = connection
+	if rb.publishers, err = newPool(connection, conf.Publish.channels()); err != nil {
+		rb.cancel()
+		_ = connection.Close()
+		return nil, err
 	}
+	// Monitor
+	go rb.monitor(connection)
 	// Success
-	return nil
+	return rb, nil
 }
 
-func (r *rabbitConnection) newChannel() error {
-	id, err := uuid.NewRandom()
-	if err != nil {
-		return err
+// Close stops the consumers and waits for their in-flight handlers, waits
+// for in-flight publishes to return their channels, then closes the
+// connection and stops the monitor. The service is unusable afterwards.
+func (r *rabbitConnection) Close() error {
+	r.mutex.Lock()
+	if r.ctx.Err() != nil {
+		r.mutex.Unlock()
+		return ErrConnectionClosed
 	}
-	r.uuid = id.String()
-	r.channel, err = r.connection.Channel()
-	if err != nil {
-		return err
+	r.cancel()
+	r.mutex.Unlock()
+	// Consumers cancel first so in-flight handlers can still ack
+	r.consumers.Wait()
+	r.publishers.close()
+	r.mutex.Lock()
+	connection := r.connection
+	r.connection = nil
+	r.mutex.Unlock()
+	var err error
+	if connection != nil && !connection.IsClosed() {
+		err = connection.Close()
 	}
+	<-r.monitored
 	// Success
-	return nil
+	return err
 }
 
-func (r *rabbitConnection) monitor() {
-	// Reconnect connection
-	go func() {
-		for {
-			if r.connection != nil {
-				reason, ok := <-r.connection.NotifyClose(make(chan *amqp.Error))
-				if !ok {
-					r.logger.Info("connection closed")
-					break
-				}
-				if reason != nil {
-					r.logger.Infof("connection closed, reason: %v", reason)
-				}
-			}
-			// Reconnect
-			for {
-				clock.Sleep(ScheduleReconnect)
-				if err := r.newConnection(); err == nil {
-					r.logger.Info("recreate connection success!")
-					break
-				} else {
-					r.logger.Errorf("recreate connection failed, reason: %v", err)
-				}
-			}
+// connect dials the first connection following the connect retry option
+func (r *rabbitConnection) connect() (*amqp.Connection, error) {
+	for attempt := 1; ; attempt++ {
+		connection, err := r.dial()
+		if err == nil {
+			return connection, nil
 		}
-
-	}()
-	// Reconnect channel
-	go func() {
-		for {
-			if r.channel != nil {
-				reason, ok := <-r.channel.NotifyClose(make(chan *amqp.Error))
-				if !ok {
-					r.logger.Info("channel closed")
-					break
-				}
-				if reason != nil {
-					r.logger.Infof("channel closed, reason: %v", reason)
-				}
-			}
-			// Reconnect
-			for {
-				clock.Sleep(ScheduleReconnect)
-				if r.connection != nil && !r.connection.IsClosed() {
-					if err := r.newChannel(); err == nil {
-						r.logger.Info("recreate channel success!")
-						break
-					} else {
-						r.logger.Errorf("recreate channel failed, reason: %v", err)
-					}
-				}
-			}
+		if attempt >= r.options.connectAttempts {
+			return nil, err
 		}
-	}()
+		r.logger.Errorf("connect failed (attempt %d/%d), reason: %v", attempt, r.options.connectAttempts, err)
+		clock.Sleep(r.options.connectInterval)
+	}
 }
 
-func (r *rabbitConnection) DeclareExchange(name, kind string, durable bool) error {
+func (r *rabbitConnection) dial() (*amqp.Connection, error) {
+	config := amqp.Config{
+		Vhost:           r.options.vhost,
+		Heartbeat:       time.Duration(r.options.heartbeat),
+		TLSClientConfig: r.options.tlsConfig,
+		Locale:          "en_US",
+		Properties:      amqp.Table{},
+		Dial:            amqp.DefaultDial(time.Duration(r.options.timeout())),
+	}
+	if r.options.connectionName != "" {
+		config.Properties["connection_name"] = r.options.connectionName
+	}
+	// A TLS config implies amqps, the scheme decides whether amqp uses TLS
+	conf := r.config
+	if r.options.tlsConfig != nil {
+		conf.Secure = true
+	}
 	// Success
-	return r.channel.ExchangeDeclare(name, kind, durable, false, false, false, nil)
+	return amqp.DialConfig(conf.String(), config)
 }
 
-func (r *rabbitConnection) DeclareQueue(name string, durable bool, priority int, ttl clock.Duration) error {
-	table := amqp.Table{}
-	if priority > 0 {
-		table["x-max-priority"] = priority
+// conn returns the current connection, consumers and publishers open their
+// own channels on it so nothing holds a channel across a reconnect
+func (r *rabbitConnection) conn() (*amqp.Connection, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.connection == nil || r.connection.IsClosed() {
+		return nil, ErrConnectionClosed
 	}
-	if ttl > 0 {
-		table["message-ttl"] = ttl.Milliseconds()
+	// Success
+	return r.connection, nil
+}
+
+// channel runs fn on a short lived channel, a failed declaration closes its
+// channel on the broker side and must not affect other callers
+func (r *rabbitConnection) channel(fn func(channel *amqp.Channel) error) error {
+	connection, err := r.conn()
+	if err != nil {
+		return err
 	}
-	if _, err := r.channel.QueueDeclare(name, durable, false, false, false, table); err != nil {
+	// Success
+	return withChannel(connection, fn)
+}
+
+func withChannel(connection *amqp.Connection, fn func(channel *amqp.Channel) error) error {
+	channel, err := connection.Channel()
+	if err != nil {
 		return err
 	}
+	defer channel.Close()
 	// Success
-	return nil
+	return fn(channel)
 }
 
-func (r *rabbitConnection) BindQueue(queue, exchange string) error {
+func (r *rabbitConnection) Publish(exchange, queue string, message Message) error {
+	publishing, err := newPublishing(message)
+	if err != nil {
+		return err
+	}
 	// Success
-	return r.channel.QueueBind(queue, queue, exchange, false, nil)
+	return r.send(exchange, queue, message.Mandatory, publishing)
 }
 
-func (r *rabbitConnection) Qos(prefetchCount int) error {
-	if err := r.channel.Qos(prefetchCount, 0, false); err != nil {
+func (r *rabbitConnection) PublishOnce(exchange, key string, message Message) error {
+	publishing, err := newPublishing(message)
+	if err != nil {
 		return err
 	}
 	// Success
-	return nil
+	return r.publish(exchange, key, message.Mandatory, publishing, time.Duration(r.config.Publish.timeout()))
 }
 
-func (r *rabbitConnection) Publish(exchange, queue string, message Message) error {
+func newPublishing(message Message) (amqp.Publishing, error) {
 	if message.ContentType == "" {
 		message.ContentType = MIMETextPlain
 	}
 	if message.Mode == 0 {
 		message.Mode = Transient
 	}
-	err := r.channel.Publish(
-		exchange,
-		queue,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType:  message.ContentType,
-			DeliveryMode: message.Mode,
-			Priority:     message.Priority,
-			Body:         message.Body,
-		},
-	)
-	if err != nil {
-		r.logger.Errorf("publish failed, reason: %v", err)
-		clock.Sleep(SchedulePublish)
-		return r.Publish(exchange, queue, message)
+	if message.MessageID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return amqp.Publishing{}, err
+		}
+		message.MessageID = id.String()
+	}
+	publishing := amqp.Publishing{
+		Headers:         amqp.Table(message.Headers),
+		ContentType:     message.ContentType,
+		ContentEncoding: message.ContentEncoding,
+		DeliveryMode:    message.Mode,
+		Priority:        message.Priority,
+		CorrelationId:   message.CorrelationID,
+		ReplyTo:         message.ReplyTo,
+		MessageId:       message.MessageID,
+		Timestamp:       message.Timestamp,
+		Type:            message.Type,
+		AppId:           message.AppID,
+		Body:            message.Body,
+	}
+	if message.Expiration > 0 {
+		publishing.Expiration = strconv.FormatInt(message.Expiration.Milliseconds(), 10)
 	}
 	// Success
-	return nil
+	return publishing, nil
 }
 
-func (r *rabbitConnection) Consume(queue string, auto bool, prefetchCount int, callback Consumer) error {
-	if prefetchCount == 0 {
-		prefetchCount = 1
-	}
-	if err := r.Qos(prefetchCount); err != nil {
-		r.logger.Errorf("qos failed, reason: %v", err)
-		clock.Sleep(ScheduleConsume)
-		return r.Consume(queue, auto, prefetchCount, callback)
-	}
-	deliveries := make(chan amqp.Delivery)
-	go func() {
-		for {
-			d, err := r.channel.Consume(queue, r.uuid, auto, false, false, false, nil)
-			if err != nil {
-				clock.Sleep(ScheduleConsume)
-				continue
-			}
-			for msg := range d {
-				deliveries <- msg
-			}
+// send publishes with confirms, retrying transient failures up to the
+// configured limit. Unroutable messages are reported without retrying.
+func (r *rabbitConnection) send(exchange, key string, mandatory bool, publishing amqp.Publishing) error {
+	var err error
+	maxRetries := r.config.Publish.maxRetries()
+	timeout := time.Duration(r.config.Publish.timeout())
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			clock.Sleep(r.config.Publish.retryInterval())
 		}
-	}()
-	// Process
-	for msg := range deliveries {
-		err := callback(msg.Body)
+		err = r.publish(exchange, key, mandatory, publishing, timeout)
 		if err == nil {
-			if !auto {
-				// Ack
-				msg.Ack(false)
-			}
-		} else {
-			if !auto {
-				// Requeue
-				msg.Nack(false, true)
-			}
+			return nil
+		}
+		if errors.Is(err, ErrUnroutable) {
+			return err
 		}
+		r.logger.Errorf("publish failed (attempt %d/%d), reason: %v", attempt+1, maxRetries+1, err)
+	}
+	return &PublishError{Attempts: maxRetries + 1, Err: err}
+}
+
+func (r *rabbitConnection) publish(exchange, key string, mandatory bool, publishing amqp.Publishing, timeout time.Duration) error {
+	p, err := r.publishers.acquire(r.conn, timeout)
+	if err != nil {
+		return err
 	}
+	defer r.publishers.release(p)
 	// Success
-	return nil
+	return p.publish(exchange, key, mandatory, publishing, timeout)
+}
+
+func (r *rabbitConnection) Consume(queue string, auto bool, prefetchCount int, callback Consumer) error {
+	options := ConsumeOptions{AutoAck: auto, PrefetchCount: prefetchCount, Concurrency: 1}
+	for {
+		sub, err := r.ConsumeContext(context.Background(), queue, options, callback)
+		if err != nil {
+			r.logger.Errorf("consume failed, reason: %v", err)
+			clock.Sleep(ScheduleConsume)
+			continue
+		}
+		// Success
+		return sub.Wait()
+	}
 }
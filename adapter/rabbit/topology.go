@@ -0,0 +1,192 @@
+package rabbit
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func (r *rabbitConnection) DeclareExchange(name, kind string, durable bool) error {
+	// Success
+	return r.DeclareExchangeWithOptions(name, kind, ExchangeOptions{Durable: durable})
+}
+
+func (r *rabbitConnection) DeclareExchangeWithOptions(name, kind string, options ExchangeOptions) error {
+	// Success
+	return r.declare(&declaration{
+		key:   exchangeKey(name),
+		stage: stageExchange,
+		apply: func(channel *amqp.Channel) error {
+			return channel.ExchangeDeclare(name, kind, options.Durable, options.AutoDelete, options.Internal, false, amqp.Table(options.Arguments))
+		},
+	})
+}
+
+func (r *rabbitConnection) DeclareQueue(name string, durable bool, priority int, ttl clock.Duration) error {
+	// Success
+	return r.DeclareQueueWithOptions(name, QueueOptions{Durable: durable, Priority: priority, TTL: ttl})
+}
+
+func (r *rabbitConnection) DeclareQueueWithOptions(name string, options QueueOptions) error {
+	table := queueArguments(options)
+	// Success
+	return r.declare(&declaration{
+		key:   queueKey(name),
+		stage: stageQueue,
+		apply: func(channel *amqp.Channel) error {
+			_, err := channel.QueueDeclare(name, options.Durable, options.AutoDelete, options.Exclusive, false, table)
+			return err
+		},
+	})
+}
+
+func queueArguments(options QueueOptions) amqp.Table {
+	table := amqp.Table{}
+	for key, value := range options.Arguments {
+		table[key] = value
+	}
+	if options.Type != "" {
+		table["x-queue-type"] = options.Type
+	}
+	if options.Lazy {
+		table["x-queue-mode"] = "lazy"
+	}
+	if options.Priority > 0 {
+		table["x-max-priority"] = options.Priority
+	}
+	if options.TTL > 0 {
+		table["x-message-ttl"] = options.TTL.Milliseconds()
+	}
+	if options.Expires > 0 {
+		table["x-expires"] = options.Expires.Milliseconds()
+	}
+	if options.MaxLength > 0 {
+		table["x-max-length"] = options.MaxLength
+	}
+	if options.MaxLengthBytes > 0 {
+		table["x-max-length-bytes"] = options.MaxLengthBytes
+	}
+	if options.Overflow != "" {
+		table["x-overflow"] = options.Overflow
+	}
+	if options.DeadLetterExchange != "" || options.DeadLetterRoutingKey != "" {
+		table["x-dead-letter-exchange"] = options.DeadLetterExchange
+	}
+	if options.DeadLetterRoutingKey != "" {
+		table["x-dead-letter-routing-key"] = options.DeadLetterRoutingKey
+	}
+	// Success
+	return table
+}
+
+func (r *rabbitConnection) BindQueue(queue, exchange string) error {
+	// Success
+	return r.BindQueueWithOptions(queue, exchange, BindOptions{RoutingKey: queue})
+}
+
+func (r *rabbitConnection) BindQueueWithOptions(queue, exchange string, options BindOptions) error {
+	// Success
+	return r.declare(&declaration{
+		key:         bindingKey("queue", queue, exchange, options),
+		stage:       stageQueueBinding,
+		source:      exchange,
+		destination: queue,
+		apply: func(channel *amqp.Channel) error {
+			return channel.QueueBind(queue, options.RoutingKey, exchange, false, amqp.Table(options.Arguments))
+		},
+	})
+}
+
+func (r *rabbitConnection) UnbindQueue(queue, exchange string, options BindOptions) error {
+	err := r.channel(func(channel *amqp.Channel) error {
+		return channel.QueueUnbind(queue, options.RoutingKey, exchange, amqp.Table(options.Arguments))
+	})
+	if err != nil {
+		return err
+	}
+	r.topology.remove(matchKey(bindingKey("queue", queue, exchange, options)))
+	// Success
+	return nil
+}
+
+func (r *rabbitConnection) BindExchange(destination, source string, options BindOptions) error {
+	// Success
+	return r.declare(&declaration{
+		key:         bindingKey("exchange", destination, source, options),
+		stage:       stageExchangeBinding,
+		source:      source,
+		destination: destination,
+		apply: func(channel *amqp.Channel) error {
+			return channel.ExchangeBind(destination, options.RoutingKey, source, false, amqp.Table(options.Arguments))
+		},
+	})
+}
+
+func (r *rabbitConnection) UnbindExchange(destination, source string, options BindOptions) error {
+	err := r.channel(func(channel *amqp.Channel) error {
+		return channel.ExchangeUnbind(destination, options.RoutingKey, source, false, amqp.Table(options.Arguments))
+	})
+	if err != nil {
+		return err
+	}
+	r.topology.remove(matchKey(bindingKey("exchange", destination, source, options)))
+	// Success
+	return nil
+}
+
+func (r *rabbitConnection) DeleteExchange(name string, ifUnused bool) error {
+	err := r.channel(func(channel *amqp.Channel) error {
+		return channel.ExchangeDelete(name, ifUnused, false)
+	})
+	if err != nil {
+		return err
+	}
+	// Bindings from the exchange are dropped by the broker, and so are
+	// exchange bindings into it
+	r.topology.remove(func(entry *declaration) bool {
+		return entry.key == exchangeKey(name) || entry.source == name ||
+			(entry.stage == stageExchangeBinding && entry.destination == name)
+	})
+	// Success
+	return nil
+}
+
+// DeleteQueue removes the queue and returns the number of messages it held
+func (r *rabbitConnection) DeleteQueue(name string, ifUnused, ifEmpty bool) (int, error) {
+	var count int
+	err := r.channel(func(channel *amqp.Channel) error {
+		var err error
+		count, err = channel.QueueDelete(name, ifUnused, ifEmpty, false)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.topology.remove(func(entry *declaration) bool {
+		return entry.key == queueKey(name) || (entry.stage == stageQueueBinding && entry.destination == name)
+	})
+	// Success
+	return count, nil
+}
+
+func exchangeKey(name string) string {
+	// Success
+	return "exchange:" + name
+}
+
+func queueKey(name string) string {
+	// Success
+	return "queue:" + name
+}
+
+func bindingKey(kind, destination, source string, options BindOptions) string {
+	// Success
+	return fmt.Sprintf("%s-binding:%s:%s:%s:%v", kind, destination, source, options.RoutingKey, options.Arguments)
+}
+
+func matchKey(key string) func(entry *declaration) bool {
+	// Success
+	return func(entry *declaration) bool { return entry.key == key }
+}
@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+const (
+	// negativeValue marks a cached not found, it is not valid JSON so no
+	// encoded value can collide with it
+	negativeValue = "!not-found"
+
+	// scriptGet reads a cached value. Unlike Service.Get it reports client
+	// errors, so a failing redis is not taken for a miss.
+	scriptGet = `return redis.call("GET", KEYS[1])`
+	// scriptTag adds a key to a tag set, only ever extending the set ttl so
+	// it outlives every key it indexes. A key without ttl makes the set
+	// persistent, and a persistent set is never given a ttl again.
+	scriptTag = `local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+	return 1
+end
+local current = redis.call("PTTL", KEYS[1])
+if existed == 0 or (current >= 0 and current < ttl) then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1`
+)
+
+type cache struct {
+	service Service
+	group   singleflight.Group
+	config  CacheConfig
+}
+
+func NewCache(service Service, conf CacheConfig) Cache {
+	// Success
+	return &cache{service: service, config: conf}
+}
+
+func (c *cache) GetOrLoad(key string, ttl clock.Duration, loader Loader, pointer interface{}, tags ...string) error {
+	value, err := c.get(key)
+	if errors.Is(err, ErrNotFound) {
+		// Concurrent misses in this process share one load
+		var shared interface{}
+		shared, err, _ = c.group.Do(key, func() (interface{}, error) {
+			return c.load(key, ttl, loader, tags)
+		})
+		value, _ = shared.(string)
+	}
+	if err != nil {
+		return err
+	}
+	if value == negativeValue {
+		return ErrNotFound
+	}
+	// Success
+	return json.Unmarshal([]byte(value), pointer)
+}
+
+// load takes a short lock so a single process calls the loader. The others
+// wait for its value and only load themselves when the wait runs out.
+func (c *cache) load(key string, ttl clock.Duration, loader Loader, tags []string) (string, error) {
+	lock, err := c.service.TryLock(KeyPrefixCacheLock+key, c.config.lockTTL())
+	if errors.Is(err, ErrLockNotObtained) {
+		if value, ok := c.wait(key); ok {
+			return value, nil
+		}
+	} else if err != nil {
+		return "", err
+	} else {
+		defer func() { _ = lock.Release() }()
+		// The previous holder may have filled the key since our miss
+		if value, err := c.get(key); err == nil {
+			return value, nil
+		}
+	}
+	result, err := loader()
+	if c.config.notFound(err) {
+		if c.config.NegativeTTL > 0 {
+			if err = c.store(key, negativeValue, c.config.NegativeTTL, tags); err != nil {
+				return "", err
+			}
+		}
+		return negativeValue, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	bts, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	if err = c.store(key, string(bts), ttl, tags); err != nil {
+		return "", err
+	}
+	// Success
+	return string(bts), nil
+}
+
+func (c *cache) wait(key string) (string, bool) {
+	for waited := clock.Duration(0); waited < c.config.lockWait(); waited += DefaultLockRetryInterval {
+		clock.Sleep(DefaultLockRetryInterval)
+		if value, err := c.get(key); err == nil {
+			return value, true
+		}
+	}
+	// Success
+	return "", false
+}
+
+func (c *cache) get(key string) (string, error) {
+	result, err := c.service.Eval(scriptGet, []string{KeyPrefixCache + key})
+	if err != nil {
+		return "", err
+	}
+	value, _ := result.(string)
+	// Success
+	return value, nil
+}
+
+func (c *cache) Set(key string, value interface{}, ttl clock.Duration, tags ...string) error {
+	bts, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	// Success
+	return c.store(key, string(bts), ttl, tags)
+}
+
+func (c *cache) store(key, value string, ttl clock.Duration, tags []string) error {
+	if jitter := c.config.jitter(); jitter > 0 && ttl > 0 {
+		ttl += clock.Duration(randomFloat64() * jitter * float64(ttl))
+	}
+	if err := c.service.Set(KeyPrefixCache+key, value, ttl); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := c.service.Eval(scriptTag, []string{KeyPrefixCacheTag + tag}, KeyPrefixCache+key, ttl.Milliseconds()); err != nil {
+			return err
+		}
+	}
+	// Success
+	return nil
+}
+
+func (c *cache) Invalidate(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, KeyPrefixCache+key)
+	}
+	// Success
+	return c.service.Delete(prefixed...)
+}
+
+func (c *cache) InvalidateTags(tags ...string) error {
+	for _, tag := range tags {
+		keys, err := c.service.SMembers(KeyPrefixCacheTag + tag)
+		if err != nil {
+			return err
+		}
+		if err = c.service.Delete(append(keys, KeyPrefixCacheTag+tag)...); err != nil {
+			return err
+		}
+	}
+	// Success
+	return nil
+}
@@ -0,0 +1,217 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/h14yhv/golang-lib/adapter/elastic"
+	"github.com/h14yhv/golang-lib/adapter/mongo"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	service, server := newService(t)
+	cache := NewCache(service, CacheConfig{})
+	var calls int32
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return testItem{Name: "a", Count: 1}, nil
+	}
+	for i := 0; i < 2; i++ {
+		var item testItem
+		if err := cache.GetOrLoad("item", clock.Minute, loader, &item); err != nil || item.Name != "a" {
+			t.Fatalf("get or load returned %+v, %v", item, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+	if ttl := server.TTL(KeyPrefixCache + "item"); ttl != time.Minute {
+		t.Fatalf("cached key ttl is %v, want 1m", ttl)
+	}
+	failed := errors.New("failed")
+	var item testItem
+	if err := cache.GetOrLoad("other", clock.Minute, func() (interface{}, error) { return nil, failed }, &item); err != failed {
+		t.Fatalf("get or load returned %v, want the loader error", err)
+	}
+	if server.Exists(KeyPrefixCache + "other") {
+		t.Fatal("a loader error was cached")
+	}
+}
+
+func TestGetOrLoadSharesConcurrentMisses(t *testing.T) {
+	service, _ := newService(t)
+	cache := NewCache(service, CacheConfig{})
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return testItem{Name: "a"}, nil
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var item testItem
+			errs <- cache.GetOrLoad("item", clock.Minute, loader, &item)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("get or load: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+}
+
+func TestGetOrLoadNotFound(t *testing.T) {
+	service, server := newService(t)
+	var calls int32
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrNotFound
+	}
+	cache := NewCache(service, CacheConfig{})
+	var item testItem
+	for i := 0; i < 2; i++ {
+		if err := cache.GetOrLoad("missing", clock.Minute, loader, &item); err != ErrNotFound {
+			t.Fatalf("get or load returned %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("loader called %d times without negative caching, want 2", calls)
+	}
+	cache = NewCache(service, CacheConfig{NegativeTTL: clock.Second})
+	for i := 0; i < 2; i++ {
+		if err := cache.GetOrLoad("negative", clock.Minute, loader, &item); err != ErrNotFound {
+			t.Fatalf("get or load returned %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("loader called %d times with negative caching, want 3", calls)
+	}
+	if ttl := server.TTL(KeyPrefixCache + "negative"); ttl != time.Second {
+		t.Fatalf("cached miss ttl is %v, want the negative ttl", ttl)
+	}
+}
+
+func TestGetOrLoadNotFoundPredicate(t *testing.T) {
+	service, _ := newService(t)
+	var item testItem
+	cache := NewCache(service, CacheConfig{NegativeTTL: clock.Minute})
+	// The lookups of the other adapters are misses by default
+	for i, missing := range []error{mongo.ErrNotFound, elastic.ErrNotFound} {
+		loader := func() (interface{}, error) { return nil, missing }
+		if err := cache.GetOrLoad(fmt.Sprintf("adapter-%d", i), clock.Minute, loader, &item); err != ErrNotFound {
+			t.Fatalf("get or load returned %v, want ErrNotFound for %v", err, missing)
+		}
+	}
+	missing := errors.New("no such row")
+	loader := func() (interface{}, error) { return nil, missing }
+	if err := cache.GetOrLoad("item", clock.Minute, loader, &item); err != missing {
+		t.Fatalf("get or load returned %v, want the loader error", err)
+	}
+	cache = NewCache(service, CacheConfig{NegativeTTL: clock.Minute, NotFound: func(err error) bool { return err == missing }})
+	if err := cache.GetOrLoad("item", clock.Minute, loader, &item); err != ErrNotFound {
+		t.Fatalf("get or load returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetOrLoadClientError(t *testing.T) {
+	service, server := newService(t)
+	cache := NewCache(service, CacheConfig{})
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return testItem{Name: "a"}, nil
+	}
+	server.Close()
+	var item testItem
+	if err := cache.GetOrLoad("item", clock.Minute, loader, &item); err == nil {
+		t.Fatal("get or load succeeded with redis down")
+	}
+	if calls != 0 {
+		t.Fatalf("loader called %d times with redis down, want a client error not a miss", calls)
+	}
+}
+
+func TestTagTTL(t *testing.T) {
+	service, server := newService(t)
+	cache := NewCache(service, CacheConfig{})
+	tag := KeyPrefixCacheTag + "users"
+	if err := cache.Set("a", 1, clock.Minute, "users"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if ttl := server.TTL(tag); ttl != time.Minute {
+		t.Fatalf("tag ttl is %v, want the key ttl", ttl)
+	}
+	if err := cache.Set("b", 1, clock.Second, "users"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if ttl := server.TTL(tag); ttl != time.Minute {
+		t.Fatalf("tag ttl is %v after a shorter key, want it kept", ttl)
+	}
+	// A key without ttl must stay indexed as long as it lives
+	if err := cache.Set("c", 1, 0, "users"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !server.Exists(tag) || server.TTL(tag) != 0 {
+		t.Fatalf("tag ttl is %v after a key without ttl, want none", server.TTL(tag))
+	}
+	if err := cache.Set("d", 1, clock.Hour, "users"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if ttl := server.TTL(tag); ttl != 0 {
+		t.Fatalf("persistent tag was given a ttl of %v", ttl)
+	}
+	// The first key of a tag without ttl persists the set from the start
+	if err := cache.Set("e", 1, 0, "orders"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !server.Exists(KeyPrefixCacheTag + "orders") {
+		t.Fatal("tag set of a key without ttl was deleted")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	service, server := newService(t)
+	cache := NewCache(service, CacheConfig{})
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cache.Set(key, key, clock.Minute, "letters"); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	if err := cache.Set("d", "d", clock.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := cache.Invalidate("a"); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if server.Exists(KeyPrefixCache + "a") {
+		t.Fatal("invalidate kept the key")
+	}
+	if err := cache.InvalidateTags("letters"); err != nil {
+		t.Fatalf("invalidate tags: %v", err)
+	}
+	for _, key := range []string{KeyPrefixCache + "b", KeyPrefixCache + "c", KeyPrefixCacheTag + "letters"} {
+		if server.Exists(key) {
+			t.Fatalf("invalidate tags kept %s", key)
+		}
+	}
+	if !server.Exists(KeyPrefixCache + "d") {
+		t.Fatal("invalidate tags dropped an untagged key")
+	}
+}
@@ -1,7 +1,69 @@
 package redis
 
-type Config struct {
-	Address  string `json:"address" yaml:"address"`
-	Password string `json:"password" yaml:"password"`
-	Db       int    `json:"db" yaml:"db"`
+import (
+	"errors"
+
+	"github.com/h14yhv/golang-lib/adapter/elastic"
+	"github.com/h14yhv/golang-lib/adapter/mongo"
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type (
+	Config struct {
+		Address  string `json:"address" yaml:"address"`
+		Password string `json:"password" yaml:"password"`
+		Db       int    `json:"db" yaml:"db"`
+	}
+
+	CacheConfig struct {
+		// NegativeTTL caches not found results, 0 disables it
+		NegativeTTL clock.Duration `json:"negative_ttl" yaml:"negative_ttl"`
+		// Jitter adds up to this fraction of the ttl, so keys written together
+		// do not expire together
+		Jitter float64 `json:"jitter" yaml:"jitter"`
+		// LockTTL bounds the load of one process while the others wait
+		LockTTL clock.Duration `json:"lock_ttl" yaml:"lock_ttl"`
+		// LockWait is how long other processes wait for that load before
+		// loading themselves
+		LockWait clock.Duration `json:"lock_wait" yaml:"lock_wait"`
+		// NotFound tells which loader errors are a miss to cache, by default
+		// those matching ErrNotFound or the mongo and elastic ErrNotFound.
+		// Set it for loaders reporting a miss with another error.
+		NotFound func(err error) bool `json:"-" yaml:"-"`
+	}
+)
+
+func (conf *CacheConfig) jitter() float64 {
+	if conf.Jitter < 0 {
+		return 0
+	}
+	// Success
+	return conf.Jitter
+}
+
+func (conf *CacheConfig) lockTTL() clock.Duration {
+	if conf.LockTTL <= 0 {
+		return DefaultCacheLockTTL
+	}
+	// Success
+	return conf.LockTTL
+}
+
+func (conf *CacheConfig) lockWait() clock.Duration {
+	if conf.LockWait <= 0 {
+		return DefaultCacheLockWait
+	}
+	// Success
+	return conf.LockWait
+}
+
+func (conf *CacheConfig) notFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if conf.NotFound == nil {
+		return errors.Is(err, ErrNotFound) || errors.Is(err, mongo.ErrNotFound) || errors.Is(err, elastic.ErrNotFound)
+	}
+	// Success
+	return conf.NotFound(err)
 }
@@ -1,5 +1,17 @@
 package redis
 
+import "github.com/h14yhv/golang-lib/clock"
+
 const (
 	Module = "REDIS"
+
+	DefaultLockRetryInterval = 100 * clock.Millisecond
+
+	DefaultCacheLockTTL  = 5 * clock.Second
+	DefaultCacheLockWait = 3 * clock.Second
+
+	KeyPrefixRateLimit = "ratelimit:"
+	KeyPrefixCache     = "cache:"
+	KeyPrefixCacheLock = "cache-lock:"
+	KeyPrefixCacheTag  = "cache-tag:"
 )
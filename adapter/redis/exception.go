@@ -1,5 +1,22 @@
 package redis
 
+import "errors"
+
 const (
-	NotFoundError = "not found"
+	NotFoundError          = "not found"
+	ResultNotASlicePointer = "result not a slice pointer"
+	LockNotObtainedError   = "lock not obtained"
+	LockNotHeldError       = "lock not held"
+	UnexpectedReplyError   = "unexpected reply"
+	InvalidTTLError        = "ttl must be at least a millisecond"
+	InvalidRateLimitError  = "rate limit needs a positive limit and a window of at least a millisecond"
+)
+
+var (
+	ErrNotFound         = errors.New(NotFoundError)
+	ErrLockNotObtained  = errors.New(LockNotObtainedError)
+	ErrLockNotHeld      = errors.New(LockNotHeldError)
+	ErrUnexpectedReply  = errors.New(UnexpectedReplyError)
+	ErrInvalidTTL       = errors.New(InvalidTTLError)
+	ErrInvalidRateLimit = errors.New(InvalidRateLimitError)
 )
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+)
+
+func (r *redisConnector) HSet(key, field, value string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.HSet(context.Background(), key, field, value).Err()
+}
+
+func (r *redisConnector) HSetObject(key, field string, value interface{}) error {
+	bts, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	// Success
+	return r.HSet(key, field, string(bts))
+}
+
+func (r *redisConnector) HGet(key, field string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, err := r.con.HGet(context.Background(), key, field).Result()
+	// Success
+	return result, notFound(err)
+}
+
+func (r *redisConnector) HGetObject(key, field string, pointer interface{}) error {
+	result, err := r.HGet(key, field)
+	if err != nil {
+		return err
+	}
+	// Success
+	return json.Unmarshal([]byte(result), pointer)
+}
+
+func (r *redisConnector) HGetAll(key string) (map[string]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.HGetAll(context.Background(), key).Result()
+}
+
+func (r *redisConnector) HIncrBy(key, field string, increment int64) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.HIncrBy(context.Background(), key, field, increment).Result()
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"time"
 
 	"github.com/h14yhv/golang-lib/clock"
@@ -13,10 +14,80 @@ type (
 		Delete(keys ...string) error
 		Expire(key string, ttl clock.Duration) error
 		ExpireAt(key string, tm time.Time) error
+		// Eval runs a Lua script, a nil reply returns ErrNotFound
+		Eval(script string, keys []string, args ...interface{}) (interface{}, error)
 		// String
 		Set(key, value string, ttl clock.Duration) error
+		// SetNX sets the key only when it does not exist and reports whether it did
+		SetNX(key, value string, ttl clock.Duration) (bool, error)
 		SetObject(key string, value interface{}, ttl clock.Duration) error
 		Get(key string) (string, error)
 		GetObject(key string, pointer interface{}) error
+		// Hash
+		HSet(key, field, value string) error
+		HSetObject(key, field string, value interface{}) error
+		HGet(key, field string) (string, error)
+		HGetObject(key, field string, pointer interface{}) error
+		HGetAll(key string) (map[string]string, error)
+		HIncrBy(key, field string, increment int64) (int64, error)
+		// List
+		LPush(key string, values ...string) (int64, error)
+		LPushObject(key string, values ...interface{}) (int64, error)
+		RPop(key string) (string, error)
+		RPopObject(key string, pointer interface{}) error
+		// BRPop waits up to timeout (0 forever) and returns the key and value
+		BRPop(timeout clock.Duration, keys ...string) (string, string, error)
+		BRPopObject(timeout clock.Duration, pointer interface{}, keys ...string) (string, error)
+		LRange(key string, start, stop int64) ([]string, error)
+		LRangeObject(key string, start, stop int64, results interface{}) error
+		// Set
+		SAdd(key string, members ...string) (int64, error)
+		SAddObject(key string, members ...interface{}) (int64, error)
+		SRem(key string, members ...string) (int64, error)
+		SRemObject(key string, members ...interface{}) (int64, error)
+		SMembers(key string) ([]string, error)
+		SMembersObject(key string, results interface{}) error
+		SIsMember(key, member string) (bool, error)
+		SIsMemberObject(key string, member interface{}) (bool, error)
+		// Sorted set
+		ZAdd(key string, members ...ScoredMember) (int64, error)
+		ZAddObject(key string, score float64, member interface{}) (int64, error)
+		ZRangeByScore(key, min, max string, offset, count int64) ([]ScoredMember, error)
+		ZRangeByScoreObject(key, min, max string, offset, count int64, results interface{}) ([]float64, error)
+		ZIncrBy(key string, increment float64, member string) (float64, error)
+		ZIncrByObject(key string, increment float64, member interface{}) (float64, error)
+		ZRem(key string, members ...string) (int64, error)
+		ZRemObject(key string, members ...interface{}) (int64, error)
+		// Lock
+		// TryLock takes the lock once and returns ErrLockNotObtained when it is held
+		TryLock(key string, ttl clock.Duration) (*Lock, error)
+		// Lock retries TryLock until it succeeds or ctx is done
+		Lock(ctx context.Context, key string, ttl clock.Duration) (*Lock, error)
+		// WithLock waits for the lock like Lock, then runs fn while holding it,
+		// extending the lease until fn returns. The fn context is derived from
+		// ctx and cancelled if the lease is lost.
+		WithLock(ctx context.Context, key string, ttl clock.Duration, fn func(ctx context.Context) error) error
+	}
+
+	RateLimiter interface {
+		Allow(key string) (RateLimit, error)
+		// AllowN takes n requests at once, either all or none
+		AllowN(key string, n int) (RateLimit, error)
+	}
+
+	// Loader returns the value to cache, or ErrNotFound to cache a miss.
+	// The mongo and elastic ErrNotFound are misses too, CacheConfig.NotFound
+	// accepts other errors.
+	Loader func() (interface{}, error)
+
+	// Cache is a cache-aside helper with stampede protection
+	Cache interface {
+		// GetOrLoad decodes the cached value into pointer, calling loader on a
+		// miss. A not found from the loader, cached or not, returns ErrNotFound.
+		GetOrLoad(key string, ttl clock.Duration, loader Loader, pointer interface{}, tags ...string) error
+		Set(key string, value interface{}, ttl clock.Duration, tags ...string) error
+		Invalidate(keys ...string) error
+		// InvalidateTags drops every key cached with one of the tags
+		InvalidateTags(tags ...string) error
 	}
 )
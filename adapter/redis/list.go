@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func (r *redisConnector) LPush(key string, values ...string) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.LPush(context.Background(), key, interfaces(values)...).Result()
+}
+
+func (r *redisConnector) LPushObject(key string, values ...interface{}) (int64, error) {
+	encoded, err := marshal(values)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.LPush(context.Background(), key, encoded...).Result()
+}
+
+func (r *redisConnector) RPop(key string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, err := r.con.RPop(context.Background(), key).Result()
+	// Success
+	return result, notFound(err)
+}
+
+func (r *redisConnector) RPopObject(key string, pointer interface{}) error {
+	result, err := r.RPop(key)
+	if err != nil {
+		return err
+	}
+	// Success
+	return json.Unmarshal([]byte(result), pointer)
+}
+
+// BRPop does not hold the connector mutex while it waits, so other commands
+// keep running
+func (r *redisConnector) BRPop(timeout clock.Duration, keys ...string) (string, string, error) {
+	result, err := r.client().BRPop(context.Background(), time.Duration(timeout), keys...).Result()
+	if err != nil {
+		return "", "", notFound(err)
+	}
+	// Success
+	return result[0], result[1], nil
+}
+
+func (r *redisConnector) BRPopObject(timeout clock.Duration, pointer interface{}, keys ...string) (string, error) {
+	key, result, err := r.BRPop(timeout, keys...)
+	if err != nil {
+		return "", err
+	}
+	// Success
+	return key, json.Unmarshal([]byte(result), pointer)
+}
+
+func (r *redisConnector) LRange(key string, start, stop int64) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.LRange(context.Background(), key, start, stop).Result()
+}
+
+func (r *redisConnector) LRangeObject(key string, start, stop int64, results interface{}) error {
+	values, err := r.LRange(key, start, stop)
+	if err != nil {
+		return err
+	}
+	// Success
+	return unmarshalSlice(values, results)
+}
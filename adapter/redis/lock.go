@@ -0,0 +1,234 @@
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+const (
+	// scriptRelease deletes the key only while it still holds our token
+	scriptRelease = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`
+	// scriptExtend resets the lease only while the key still holds our token
+	scriptExtend = `if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`
+)
+
+var (
+	// random is seeded once, the global source is deterministic before Go 1.20
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	// randomMutex guards random, a rand.Rand is not safe for concurrent use
+	randomMutex sync.Mutex
+)
+
+type (
+	// Lock is a lease on a key identified by a random token, so only the
+	// owner can extend or release it
+	Lock struct {
+		// ttl is read by the watchdog while Extend may change it, so it is
+		// only accessed atomically and kept first for 64-bit alignment
+		ttl     int64
+		service Service
+		key     string
+		token   string
+		mutex   sync.Mutex
+		stop    chan struct{}
+		done    chan struct{}
+	}
+)
+
+func (r *redisConnector) TryLock(key string, ttl clock.Duration) (*Lock, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	token := id.String()
+	ok, err := r.SetNX(key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotObtained
+	}
+	// Success
+	return &Lock{service: r, key: key, token: token, ttl: int64(ttl)}, nil
+}
+
+func (r *redisConnector) Lock(ctx context.Context, key string, ttl clock.Duration) (*Lock, error) {
+	for {
+		lock, err := r.TryLock(key, ttl)
+		if err != ErrLockNotObtained {
+			return lock, err
+		}
+		// Jitter keeps waiting replicas from retrying in lockstep
+		wait := DefaultLockRetryInterval + clock.Duration(randomInt63n(int64(DefaultLockRetryInterval)))
+		timer := time.NewTimer(time.Duration(wait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *redisConnector) WithLock(ctx context.Context, key string, ttl clock.Duration, fn func(ctx context.Context) error) error {
+	lock, err := r.Lock(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx = lock.Watch(ctx)
+	fnErr := fn(ctx)
+	if err = lock.Release(); err != nil && fnErr == nil {
+		return err
+	}
+	// Success
+	return fnErr
+}
+
+func (l *Lock) Key() string {
+	// Success
+	return l.key
+}
+
+func (l *Lock) Token() string {
+	// Success
+	return l.token
+}
+
+// TTL returns the lease set by TryLock or the last successful Extend
+func (l *Lock) TTL() clock.Duration {
+	// Success
+	return clock.Duration(atomic.LoadInt64(&l.ttl))
+}
+
+// Extend resets the lease to ttl, which the watchdog keeps using from then
+// on. ErrLockNotHeld means it already expired and another owner may hold
+// the key. A ttl under a millisecond would delete the key and returns
+// ErrInvalidTTL.
+func (l *Lock) Extend(ttl clock.Duration) error {
+	if ttl < clock.Millisecond {
+		return ErrInvalidTTL
+	}
+	result, err := l.service.Eval(scriptExtend, []string{l.key}, l.token, ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrLockNotHeld
+	}
+	atomic.StoreInt64(&l.ttl, int64(ttl))
+	// Success
+	return nil
+}
+
+// Release stops the watchdog and deletes the key if the lease is still ours
+func (l *Lock) Release() error {
+	l.mutex.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+		l.stop = nil
+	}
+	l.mutex.Unlock()
+	result, err := l.service.Eval(scriptRelease, []string{l.key}, l.token)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.(int64); n == 0 {
+		return ErrLockNotHeld
+	}
+	// Success
+	return nil
+}
+
+// Watch starts a watchdog extending the lease every third of its ttl until
+// Release. The returned context is cancelled once the lease is lost, on
+// Release, or when ctx is done, which also stops the watchdog.
+func (l *Lock) Watch(ctx context.Context) context.Context {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	if l.stop != nil {
+		select {
+		case <-l.done:
+		default:
+			// A watchdog is already running, follow it
+			done := l.done
+			go func() {
+				<-done
+				cancel()
+			}()
+			return ctx
+		}
+	}
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.watch(ctx, cancel, l.stop, l.done)
+	// Success
+	return ctx
+}
+
+func (l *Lock) watch(ctx context.Context, cancel context.CancelFunc, stop, done chan struct{}) {
+	defer close(done)
+	defer cancel()
+	interval := l.TTL() / 3
+	if interval <= 0 {
+		// Without a ttl the key never expires and there is nothing to extend
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+		return
+	}
+	ticker := time.NewTicker(time.Duration(interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A transient error keeps the lease until it expires, a lost
+			// lease cancels the caller
+			ttl := l.TTL()
+			if err := l.Extend(ttl); err == ErrLockNotHeld {
+				cancel()
+				return
+			}
+			// Follow a ttl changed by an explicit Extend
+			if ttl/3 > 0 && ttl/3 != interval {
+				interval = ttl / 3
+				ticker.Reset(time.Duration(interval))
+			}
+		}
+	}
+}
+
+func randomInt63n(n int64) int64 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	// Success
+	return random.Int63n(n)
+}
+
+func randomFloat64() float64 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	// Success
+	return random.Float64()
+}
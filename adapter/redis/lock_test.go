@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+func TestTryLock(t *testing.T) {
+	service, _ := newService(t)
+	lock, err := service.TryLock("job", clock.Minute)
+	if err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	if _, err = service.TryLock("job", clock.Minute); err != ErrLockNotObtained {
+		t.Fatalf("second try lock returned %v, want ErrLockNotObtained", err)
+	}
+	if err = lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err = lock.Release(); err != ErrLockNotHeld {
+		t.Fatalf("second release returned %v, want ErrLockNotHeld", err)
+	}
+	if _, err = service.TryLock("job", clock.Minute); err != nil {
+		t.Fatalf("try lock after release: %v", err)
+	}
+}
+
+func TestReleaseKeepsOtherOwner(t *testing.T) {
+	service, server := newService(t)
+	lock, err := service.TryLock("job", clock.Minute)
+	if err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	// The lease expired and another replica took the key
+	server.FastForward(time.Minute)
+	if _, err = service.TryLock("job", clock.Minute); err != nil {
+		t.Fatalf("try lock after expiry: %v", err)
+	}
+	if err = lock.Extend(clock.Minute); err != ErrLockNotHeld {
+		t.Fatalf("extend of a lost lease returned %v, want ErrLockNotHeld", err)
+	}
+	if err = lock.Release(); err != ErrLockNotHeld {
+		t.Fatalf("release of a lost lease returned %v, want ErrLockNotHeld", err)
+	}
+	if !server.Exists("job") {
+		t.Fatal("release deleted the key of another owner")
+	}
+}
+
+func TestExtendStoresTTL(t *testing.T) {
+	service, server := newService(t)
+	lock, err := service.TryLock("job", clock.Second)
+	if err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	if err = lock.Extend(2 * clock.Minute); err != nil {
+		t.Fatalf("extend: %v", err)
+	}
+	if lock.TTL() != 2*clock.Minute {
+		t.Fatalf("lock ttl is %v after extend, want 2m", lock.TTL())
+	}
+	if ttl := server.TTL("job"); ttl != 2*time.Minute {
+		t.Fatalf("key ttl is %v after extend, want 2m", ttl)
+	}
+	if err = lock.Extend(clock.Microsecond); err != ErrInvalidTTL {
+		t.Fatalf("extend below a millisecond returned %v, want ErrInvalidTTL", err)
+	}
+	if !server.Exists("job") || lock.TTL() != 2*clock.Minute {
+		t.Fatal("a rejected extend changed the lease")
+	}
+}
+
+func TestLockWaitsForContext(t *testing.T) {
+	service, _ := newService(t)
+	if _, err := service.TryLock("job", clock.Minute); err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Duration(DefaultLockRetryInterval))
+	defer cancel()
+	if _, err := service.Lock(ctx, "job", clock.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("lock of a held key returned %v, want the context error", err)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	service, server := newService(t)
+	err := service.WithLock(context.Background(), "job", clock.Minute, func(ctx context.Context) error {
+		if !server.Exists("job") {
+			t.Error("key is not held while fn runs")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("with lock: %v", err)
+	}
+	if server.Exists("job") {
+		t.Fatal("with lock did not release the key")
+	}
+	failed := errors.New("failed")
+	if err = service.WithLock(context.Background(), "job", clock.Minute, func(ctx context.Context) error { return failed }); err != failed {
+		t.Fatalf("with lock returned %v, want the fn error", err)
+	}
+}
+
+func TestWithLockWaits(t *testing.T) {
+	service, _ := newService(t)
+	lock, err := service.TryLock("job", clock.Minute)
+	if err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	go func() {
+		time.Sleep(2 * time.Duration(DefaultLockRetryInterval))
+		_ = lock.Release()
+	}()
+	ran := false
+	err = service.WithLock(context.Background(), "job", clock.Minute, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("with lock on a released key returned %v, ran %v", err, ran)
+	}
+	if _, err = service.TryLock("job", clock.Minute); err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Duration(DefaultLockRetryInterval))
+	defer cancel()
+	err = service.WithLock(ctx, "job", clock.Minute, func(ctx context.Context) error {
+		t.Error("fn ran without the lock")
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("with lock on a held key returned %v, want the context error", err)
+	}
+}
+
+func TestWatchCancelsLostLease(t *testing.T) {
+	service, server := newService(t)
+	lock, err := service.TryLock("job", 150*clock.Millisecond)
+	if err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	ctx := lock.Watch(context.Background())
+	// Another owner replaced the key, the next extension finds it lost
+	if err = server.Set("job", "other"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("watch context was not cancelled after the lease was lost")
+	}
+	if err = lock.Release(); err != ErrLockNotHeld {
+		t.Fatalf("release returned %v, want ErrLockNotHeld", err)
+	}
+}
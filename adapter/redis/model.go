@@ -0,0 +1,15 @@
+package redis
+
+import "github.com/h14yhv/golang-lib/ratelimit"
+
+type (
+	// ScoredMember is a sorted set member with its score
+	ScoredMember struct {
+		Member string
+		Score  float64
+	}
+
+	// RateLimit is the outcome of a rate limiter call, the shared
+	// ratelimit.Result so a RateLimiter satisfies ratelimit.Limiter
+	RateLimit = ratelimit.Result
+)
@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+const (
+	// scriptSlidingWindow keeps one sorted set member per request scored by
+	// its time, so the window slides with the server clock. It returns
+	// allowed, remaining, reset and retry after, times in milliseconds.
+	scriptSlidingWindow = `redis.replicate_commands()
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+local retry = 0
+if count + cost <= limit then
+	for i = 1, cost do
+		redis.call("ZADD", key, now, ARGV[4] .. ":" .. i)
+	end
+	count = count + cost
+	allowed = 1
+elseif cost > limit then
+	retry = -1
+else
+	local oldest = redis.call("ZRANGE", key, count + cost - limit - 1, count + cost - limit - 1, "WITHSCORES")
+	retry = tonumber(oldest[2]) + window - now
+end
+local reset = 0
+local newest = redis.call("ZRANGE", key, -1, -1, "WITHSCORES")
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+	redis.call("PEXPIRE", key, reset)
+end
+return {allowed, limit - count, reset, retry}`
+
+	// scriptTokenBucket refills the bucket by the time elapsed since the last
+	// request and stores the tokens left, with the same reply as above
+	scriptTokenBucket = `redis.replicate_commands()
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + tonumber(time[2]) / 1000
+local state = redis.call("HMGET", key, "tokens", "timestamp")
+local tokens = tonumber(state[1]) or burst
+local timestamp = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - timestamp) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+elseif cost > burst then
+	retry = -1
+else
+	retry = math.ceil((cost - tokens) / rate)
+end
+local reset = math.ceil((burst - tokens) / rate)
+redis.call("HSET", key, "tokens", tokens, "timestamp", now)
+redis.call("PEXPIRE", key, math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}`
+)
+
+type (
+	slidingWindow struct {
+		service Service
+		limit   int
+		window  clock.Duration
+	}
+
+	tokenBucket struct {
+		service Service
+		rate    int
+		period  clock.Duration
+		burst   int
+	}
+)
+
+// NewSlidingWindow allows limit requests in any window long interval. The
+// scripts count in milliseconds, so a shorter window returns
+// ErrInvalidRateLimit.
+func NewSlidingWindow(service Service, limit int, window clock.Duration) (RateLimiter, error) {
+	if limit <= 0 || window < clock.Millisecond {
+		return nil, ErrInvalidRateLimit
+	}
+	// Success
+	return &slidingWindow{service: service, limit: limit, window: window}, nil
+}
+
+// NewTokenBucket refills rate tokens every period up to burst tokens, a
+// burst of 0 means rate. A period under a millisecond returns
+// ErrInvalidRateLimit.
+func NewTokenBucket(service Service, rate int, period clock.Duration, burst int) (RateLimiter, error) {
+	if rate <= 0 || period < clock.Millisecond {
+		return nil, ErrInvalidRateLimit
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	// Success
+	return &tokenBucket{service: service, rate: rate, period: period, burst: burst}, nil
+}
+
+func (l *slidingWindow) Allow(key string) (RateLimit, error) {
+	// Success
+	return l.AllowN(key, 1)
+}
+
+func (l *slidingWindow) AllowN(key string, n int) (RateLimit, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return RateLimit{}, err
+	}
+	result, err := l.service.Eval(scriptSlidingWindow, []string{KeyPrefixRateLimit + key}, l.limit, l.window.Milliseconds(), n, id.String())
+	if err != nil {
+		return RateLimit{}, err
+	}
+	// Success
+	return newRateLimit(l.limit, result)
+}
+
+func (l *tokenBucket) Allow(key string) (RateLimit, error) {
+	// Success
+	return l.AllowN(key, 1)
+}
+
+func (l *tokenBucket) AllowN(key string, n int) (RateLimit, error) {
+	// Tokens per millisecond
+	rate := float64(l.rate) / float64(l.period.Milliseconds())
+	result, err := l.service.Eval(scriptTokenBucket, []string{KeyPrefixRateLimit + key}, rate, l.burst, n)
+	if err != nil {
+		return RateLimit{}, err
+	}
+	// Success
+	return newRateLimit(l.burst, result)
+}
+
+func newRateLimit(limit int, result interface{}) (RateLimit, error) {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return RateLimit{}, ErrUnexpectedReply
+	}
+	numbers := make([]int64, len(values))
+	for i, value := range values {
+		if numbers[i], ok = value.(int64); !ok {
+			return RateLimit{}, ErrUnexpectedReply
+		}
+	}
+	limited := RateLimit{
+		Allowed:    numbers[0] == 1,
+		Limit:      limit,
+		Remaining:  int(numbers[1]),
+		ResetAfter: clock.Duration(numbers[2]) * clock.Millisecond,
+		RetryAfter: clock.Duration(numbers[3]) * clock.Millisecond,
+	}
+	if numbers[3] < 0 {
+		limited.RetryAfter = -1
+	}
+	// Success
+	return limited, nil
+}
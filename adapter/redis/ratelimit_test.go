@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/ratelimit"
+)
+
+// The rest middleware takes the redis limiters as they are
+var _ ratelimit.Limiter = RateLimiter(nil)
+
+func TestInvalidRateLimit(t *testing.T) {
+	service, _ := newService(t)
+	if _, err := NewSlidingWindow(service, 10, clock.Microsecond); err != ErrInvalidRateLimit {
+		t.Fatalf("sub-millisecond window returned %v, want ErrInvalidRateLimit", err)
+	}
+	if _, err := NewSlidingWindow(service, 0, clock.Second); err != ErrInvalidRateLimit {
+		t.Fatalf("zero limit returned %v, want ErrInvalidRateLimit", err)
+	}
+	if _, err := NewTokenBucket(service, 10, 999*clock.Microsecond, 0); err != ErrInvalidRateLimit {
+		t.Fatalf("sub-millisecond period returned %v, want ErrInvalidRateLimit", err)
+	}
+	if _, err := NewTokenBucket(service, -1, clock.Second, 0); err != ErrInvalidRateLimit {
+		t.Fatalf("negative rate returned %v, want ErrInvalidRateLimit", err)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	service, server := newService(t)
+	server.SetTime(time.Unix(1600000000, 0))
+	limiter, err := NewSlidingWindow(service, 2, clock.Minute)
+	if err != nil {
+		t.Fatalf("new sliding window: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		limited, err := limiter.Allow("ip")
+		if err != nil || !limited.Allowed || limited.Remaining != 1-i {
+			t.Fatalf("request %d returned %+v, %v", i, limited, err)
+		}
+		server.SetTime(time.Unix(1600000010, 0))
+	}
+	limited, err := limiter.Allow("ip")
+	if err != nil || limited.Allowed || limited.Limit != 2 || limited.Remaining != 0 {
+		t.Fatalf("request over the limit returned %+v, %v", limited, err)
+	}
+	// The oldest request leaves the window a minute after it was made
+	if limited.RetryAfter != 50*clock.Second || limited.ResetAfter != clock.Minute {
+		t.Fatalf("retry after %v and reset after %v, want 50s and 1m", limited.RetryAfter, limited.ResetAfter)
+	}
+	if limited, err = limiter.AllowN("ip", 3); err != nil || limited.Allowed || limited.RetryAfter != -1 {
+		t.Fatalf("cost over the limit returned %+v, %v", limited, err)
+	}
+	server.SetTime(time.Unix(1600000060, 0))
+	if limited, err = limiter.Allow("ip"); err != nil || !limited.Allowed || limited.Remaining != 0 {
+		t.Fatalf("request after the window slid returned %+v, %v", limited, err)
+	}
+	if limited, err = limiter.Allow("other"); err != nil || !limited.Allowed {
+		t.Fatalf("request for another key returned %+v, %v", limited, err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	service, server := newService(t)
+	server.SetTime(time.Unix(1600000000, 0))
+	limiter, err := NewTokenBucket(service, 1, clock.Second, 3)
+	if err != nil {
+		t.Fatalf("new token bucket: %v", err)
+	}
+	limited, err := limiter.AllowN("ip", 3)
+	if err != nil || !limited.Allowed || limited.Limit != 3 || limited.Remaining != 0 {
+		t.Fatalf("burst returned %+v, %v", limited, err)
+	}
+	if limited.ResetAfter != 3*clock.Second {
+		t.Fatalf("reset after %v, want 3s", limited.ResetAfter)
+	}
+	if limited, err = limiter.Allow("ip"); err != nil || limited.Allowed || limited.RetryAfter != clock.Second {
+		t.Fatalf("request on an empty bucket returned %+v, %v", limited, err)
+	}
+	if limited, err = limiter.AllowN("ip", 4); err != nil || limited.Allowed || limited.RetryAfter != -1 {
+		t.Fatalf("cost over the burst returned %+v, %v", limited, err)
+	}
+	server.SetTime(time.Unix(1600000002, 0))
+	if limited, err = limiter.AllowN("ip", 2); err != nil || !limited.Allowed || limited.Remaining != 0 {
+		t.Fatalf("request after a refill returned %+v, %v", limited, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"reflect"
 	"sync"
 	"time"
 
@@ -16,10 +17,11 @@ import (
 )
 
 type redisConnector struct {
-	logger log.Logger
-	con    *rd.Client
-	mutex  *sync.Mutex
-	config Config
+	logger  log.Logger
+	con     *rd.Client
+	mutex   *sync.Mutex
+	scripts sync.Map
+	config  Config
 }
 
 func NewService(conf Config, tlsConf *tls.Config) Service {
@@ -69,6 +71,15 @@ func (r *redisConnector) monitor() {
 	}()
 }
 
+// client returns the current client for blocking commands, which must not
+// hold the mutex while they wait
+func (r *redisConnector) client() *rd.Client {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con
+}
+
 func (r *redisConnector) Ping() error {
 	// Success
 	return r.con.Ping(context.Background()).Err()
@@ -102,6 +113,13 @@ func (r *redisConnector) Set(key, value string, ttl clock.Duration) error {
 	return r.con.Set(context.Background(), key, value, time.Duration(ttl)).Err()
 }
 
+func (r *redisConnector) SetNX(key, value string, ttl clock.Duration) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SetNX(context.Background(), key, value, time.Duration(ttl)).Result()
+}
+
 func (r *redisConnector) SetObject(key string, value interface{}, ttl clock.Duration) error {
 	bts, err := json.Marshal(value)
 	if err != nil {
@@ -111,14 +129,16 @@ func (r *redisConnector) SetObject(key string, value interface{}, ttl clock.Dura
 	return r.Set(key, string(bts), ttl)
 }
 
+// Get returns ErrNotFound for a missing key, other client errors are not
+// reported
 func (r *redisConnector) Get(key string) (string, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	// Success
 	result, err := r.con.Get(context.Background(), key).Result()
 	if err == rd.Nil {
-		return result, errors.New(NotFoundError)
+		return result, ErrNotFound
 	}
+	// Success
 	return result, nil
 }
 
@@ -133,3 +153,67 @@ func (r *redisConnector) GetObject(key string, pointer interface{}) error {
 	// Success
 	return nil
 }
+
+// Eval runs a Lua script through EVALSHA, loading it on the first NOSCRIPT
+func (r *redisConnector) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	cached, _ := r.scripts.LoadOrStore(script, rd.NewScript(script))
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result, err := cached.(*rd.Script).Run(context.Background(), r.con, keys, args...).Result()
+	// Success
+	return result, notFound(err)
+}
+
+// notFound maps the redis nil reply to ErrNotFound
+func notFound(err error) error {
+	if err == rd.Nil {
+		return ErrNotFound
+	}
+	// Success
+	return err
+}
+
+func marshal(values []interface{}) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		bts, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, string(bts))
+	}
+	// Success
+	return result, nil
+}
+
+func interfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	// Success
+	return result
+}
+
+// unmarshalSlice decodes every JSON value into a new element appended to the
+// slice results points to
+func unmarshalSlice(values []string, results interface{}) error {
+	resultType := reflect.TypeOf(results)
+	if resultType == nil || resultType.Kind() != reflect.Ptr || resultType.Elem().Kind() != reflect.Slice {
+		return errors.New(ResultNotASlicePointer)
+	}
+	resultValue := reflect.ValueOf(results)
+	if resultValue.IsNil() {
+		return errors.New(ResultNotASlicePointer)
+	}
+	resultElemType := resultType.Elem().Elem()
+	for _, value := range values {
+		itemValue := reflect.New(resultElemType)
+		if err := json.Unmarshal([]byte(value), itemValue.Interface()); err != nil {
+			return err
+		}
+		resultValue.Elem().Set(reflect.Append(resultValue.Elem(), itemValue.Elem()))
+	}
+	// Success
+	return nil
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/h14yhv/golang-lib/clock"
+)
+
+type testItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newService(t *testing.T) (Service, *miniredis.Miniredis) {
+	t.Helper()
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(server.Close)
+	// Success
+	return NewService(Config{Address: server.Addr()}, nil), server
+}
+
+func TestString(t *testing.T) {
+	service, _ := newService(t)
+	if _, err := service.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get of a missing key returned %v, want ErrNotFound", err)
+	}
+	if err := service.SetObject("item", testItem{Name: "a", Count: 1}, clock.Minute); err != nil {
+		t.Fatalf("set object: %v", err)
+	}
+	var item testItem
+	if err := service.GetObject("item", &item); err != nil || item != (testItem{Name: "a", Count: 1}) {
+		t.Fatalf("get object returned %+v, %v", item, err)
+	}
+	if ok, err := service.SetNX("item", "b", clock.Minute); err != nil || ok {
+		t.Fatalf("setnx of an existing key returned %v, %v", ok, err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	service, _ := newService(t)
+	if err := service.HSet("user:1", "name", "alice"); err != nil {
+		t.Fatalf("hset: %v", err)
+	}
+	if err := service.HSetObject("user:1", "item", testItem{Name: "a"}); err != nil {
+		t.Fatalf("hset object: %v", err)
+	}
+	if value, err := service.HGet("user:1", "name"); err != nil || value != "alice" {
+		t.Fatalf("hget returned %q, %v", value, err)
+	}
+	if _, err := service.HGet("user:1", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("hget of a missing field returned %v, want ErrNotFound", err)
+	}
+	var item testItem
+	if err := service.HGetObject("user:1", "item", &item); err != nil || item.Name != "a" {
+		t.Fatalf("hget object returned %+v, %v", item, err)
+	}
+	if total, err := service.HIncrBy("user:1", "visits", 3); err != nil || total != 3 {
+		t.Fatalf("hincrby returned %d, %v", total, err)
+	}
+	all, err := service.HGetAll("user:1")
+	if err != nil || len(all) != 3 || all["visits"] != "3" {
+		t.Fatalf("hgetall returned %v, %v", all, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	service, _ := newService(t)
+	if n, err := service.LPushObject("queue", testItem{Name: "a"}, testItem{Name: "b"}); err != nil || n != 2 {
+		t.Fatalf("lpush object returned %d, %v", n, err)
+	}
+	var items []testItem
+	if err := service.LRangeObject("queue", 0, -1, &items); err != nil || len(items) != 2 || items[0].Name != "b" {
+		t.Fatalf("lrange object returned %+v, %v", items, err)
+	}
+	var item testItem
+	if err := service.RPopObject("queue", &item); err != nil || item.Name != "a" {
+		t.Fatalf("rpop object returned %+v, %v", item, err)
+	}
+	key, value, err := service.BRPop(clock.Second, "empty", "queue")
+	if err != nil || key != "queue" || value != `{"name":"b","count":0}` {
+		t.Fatalf("brpop returned %q %q, %v", key, value, err)
+	}
+	if _, err = service.RPop("queue"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rpop of an empty list returned %v, want ErrNotFound", err)
+	}
+	if err = service.LRangeObject("queue", 0, -1, nil); err == nil || err.Error() != ResultNotASlicePointer {
+		t.Fatalf("lrange object into nil returned %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	service, _ := newService(t)
+	if n, err := service.SAdd("tags", "a", "b", "a"); err != nil || n != 2 {
+		t.Fatalf("sadd returned %d, %v", n, err)
+	}
+	if ok, err := service.SIsMember("tags", "b"); err != nil || !ok {
+		t.Fatalf("sismember returned %v, %v", ok, err)
+	}
+	if n, err := service.SRem("tags", "b", "c"); err != nil || n != 1 {
+		t.Fatalf("srem returned %d, %v", n, err)
+	}
+	if _, err := service.SAddObject("items", testItem{Name: "a"}); err != nil {
+		t.Fatalf("sadd object: %v", err)
+	}
+	if ok, err := service.SIsMemberObject("items", testItem{Name: "a"}); err != nil || !ok {
+		t.Fatalf("sismember object returned %v, %v", ok, err)
+	}
+	var items []testItem
+	if err := service.SMembersObject("items", &items); err != nil || !reflect.DeepEqual(items, []testItem{{Name: "a"}}) {
+		t.Fatalf("smembers object returned %+v, %v", items, err)
+	}
+}
+
+func TestSortedSet(t *testing.T) {
+	service, _ := newService(t)
+	_, err := service.ZAdd("board", ScoredMember{Member: "a", Score: 1}, ScoredMember{Member: "b", Score: 5}, ScoredMember{Member: "c", Score: 3})
+	if err != nil {
+		t.Fatalf("zadd: %v", err)
+	}
+	if score, err := service.ZIncrBy("board", 3, "a"); err != nil || score != 4 {
+		t.Fatalf("zincrby returned %v, %v", score, err)
+	}
+	members, err := service.ZRangeByScore("board", "(3", "+inf", 0, 0)
+	want := []ScoredMember{{Member: "a", Score: 4}, {Member: "b", Score: 5}}
+	if err != nil || !reflect.DeepEqual(members, want) {
+		t.Fatalf("zrangebyscore returned %+v, %v", members, err)
+	}
+	if members, err = service.ZRangeByScore("board", "-inf", "+inf", 1, 1); err != nil || len(members) != 1 || members[0].Member != "a" {
+		t.Fatalf("zrangebyscore with a limit returned %+v, %v", members, err)
+	}
+	if n, err := service.ZRem("board", "a", "missing"); err != nil || n != 1 {
+		t.Fatalf("zrem returned %d, %v", n, err)
+	}
+	if _, err = service.ZAddObject("items", 2, testItem{Name: "x"}); err != nil {
+		t.Fatalf("zadd object: %v", err)
+	}
+	var items []testItem
+	scores, err := service.ZRangeByScoreObject("items", "-inf", "+inf", 0, 0, &items)
+	if err != nil || len(items) != 1 || items[0].Name != "x" || scores[0] != 2 {
+		t.Fatalf("zrangebyscore object returned %+v %v, %v", items, scores, err)
+	}
+}
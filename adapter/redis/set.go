@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+)
+
+func (r *redisConnector) SAdd(key string, members ...string) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SAdd(context.Background(), key, interfaces(members)...).Result()
+}
+
+func (r *redisConnector) SAddObject(key string, members ...interface{}) (int64, error) {
+	encoded, err := marshal(members)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SAdd(context.Background(), key, encoded...).Result()
+}
+
+func (r *redisConnector) SRem(key string, members ...string) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SRem(context.Background(), key, interfaces(members)...).Result()
+}
+
+func (r *redisConnector) SRemObject(key string, members ...interface{}) (int64, error) {
+	encoded, err := marshal(members)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SRem(context.Background(), key, encoded...).Result()
+}
+
+func (r *redisConnector) SMembers(key string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SMembers(context.Background(), key).Result()
+}
+
+func (r *redisConnector) SMembersObject(key string, results interface{}) error {
+	values, err := r.SMembers(key)
+	if err != nil {
+		return err
+	}
+	// Success
+	return unmarshalSlice(values, results)
+}
+
+func (r *redisConnector) SIsMember(key, member string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.SIsMember(context.Background(), key, member).Result()
+}
+
+func (r *redisConnector) SIsMemberObject(key string, member interface{}) (bool, error) {
+	bts, err := json.Marshal(member)
+	if err != nil {
+		return false, err
+	}
+	// Success
+	return r.SIsMember(key, string(bts))
+}
@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	rd "github.com/go-redis/redis/v8"
+)
+
+func (r *redisConnector) ZAdd(key string, members ...ScoredMember) (int64, error) {
+	items := make([]*rd.Z, 0, len(members))
+	for _, member := range members {
+		items = append(items, &rd.Z{Score: member.Score, Member: member.Member})
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.ZAdd(context.Background(), key, items...).Result()
+}
+
+func (r *redisConnector) ZAddObject(key string, score float64, member interface{}) (int64, error) {
+	bts, err := json.Marshal(member)
+	if err != nil {
+		return 0, err
+	}
+	// Success
+	return r.ZAdd(key, ScoredMember{Member: string(bts), Score: score})
+}
+
+// ZRangeByScore returns members between min and max in ascending score order.
+// Bounds follow redis syntax ("-inf", "+inf", "(1" for exclusive) and a count
+// of 0 returns everything from offset.
+func (r *redisConnector) ZRangeByScore(key, min, max string, offset, count int64) ([]ScoredMember, error) {
+	if count <= 0 {
+		count = -1
+	}
+	r.mutex.Lock()
+	items, err := r.con.ZRangeByScoreWithScores(context.Background(), key, &rd.ZRangeBy{
+		Min:    min,
+		Max:    max,
+		Offset: offset,
+		Count:  count,
+	}).Result()
+	r.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ScoredMember, 0, len(items))
+	for _, item := range items {
+		member, _ := item.Member.(string)
+		result = append(result, ScoredMember{Member: member, Score: item.Score})
+	}
+	// Success
+	return result, nil
+}
+
+// ZRangeByScoreObject decodes the members into the slice results points to
+// and returns their scores in the same order
+func (r *redisConnector) ZRangeByScoreObject(key, min, max string, offset, count int64, results interface{}) ([]float64, error) {
+	items, err := r.ZRangeByScore(key, min, max, offset, count)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(items))
+	scores := make([]float64, 0, len(items))
+	for _, item := range items {
+		values = append(values, item.Member)
+		scores = append(scores, item.Score)
+	}
+	if err = unmarshalSlice(values, results); err != nil {
+		return nil, err
+	}
+	// Success
+	return scores, nil
+}
+
+func (r *redisConnector) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.ZIncrBy(context.Background(), key, increment, member).Result()
+}
+
+func (r *redisConnector) ZIncrByObject(key string, increment float64, member interface{}) (float64, error) {
+	bts, err := json.Marshal(member)
+	if err != nil {
+		return 0, err
+	}
+	// Success
+	return r.ZIncrBy(key, increment, string(bts))
+}
+
+func (r *redisConnector) ZRem(key string, members ...string) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.ZRem(context.Background(), key, interfaces(members)...).Result()
+}
+
+func (r *redisConnector) ZRemObject(key string, members ...interface{}) (int64, error) {
+	encoded, err := marshal(members)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Success
+	return r.con.ZRem(context.Background(), key, encoded...).Result()
+}
@@ -0,0 +1,10 @@
+// Package ratelimit holds the limiter contract shared by implementations
+// such as the redis adapter and users such as the rest middleware, so
+// neither depends on the other
+package ratelimit
+
+type (
+	Limiter interface {
+		Allow(key string) (Result, error)
+	}
+)
@@ -0,0 +1,18 @@
+package ratelimit
+
+import "github.com/h14yhv/golang-lib/clock"
+
+type (
+	// Result is the outcome of a rate limiter call
+	Result struct {
+		Allowed bool
+		// Limit is the quota of the window or the bucket size
+		Limit     int
+		Remaining int
+		// ResetAfter is when the whole quota is available again
+		ResetAfter clock.Duration
+		// RetryAfter is when a denied call would be allowed, 0 when allowed
+		// and -1 when the cost exceeds the limit
+		RetryAfter clock.Duration
+	}
+)
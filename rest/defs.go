@@ -143,6 +143,10 @@ const (
 	HeaderContentSecurityPolicyReportOnly = "Content-Security-Policy-Report-Only"
 	HeaderXCSRFToken                      = "X-CSRF-Token"
 	HeaderReferrerPolicy                  = "Referrer-Policy"
+	HeaderRetryAfter                      = "Retry-After"
+	HeaderXRateLimitLimit                 = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining             = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset                 = "X-RateLimit-Reset"
 )
 
 var (
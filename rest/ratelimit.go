@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/ratelimit"
+)
+
+// RateLimit limits requests per key, the client IP when key is nil. It sets
+// the X-RateLimit-* headers, with the reset in seconds, and answers denied
+// requests with StatusTooManyRequests. A limiter error lets the request
+// through so an unavailable store does not take the API down. The redis
+// limiters satisfy ratelimit.Limiter.
+func RateLimit(limiter ratelimit.Limiter, key func(c echo.Context) string) echo.MiddlewareFunc {
+	if key == nil {
+		key = func(c echo.Context) string {
+			return c.RealIP()
+		}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			limited, err := limiter.Allow(key(c))
+			if err != nil {
+				logger.Errorf("rate limit failed, reason: %v", err)
+				return next(c)
+			}
+			header := c.Response().Header()
+			header.Set(HeaderXRateLimitLimit, strconv.Itoa(limited.Limit))
+			header.Set(HeaderXRateLimitRemaining, strconv.Itoa(limited.Remaining))
+			header.Set(HeaderXRateLimitReset, strconv.Itoa(seconds(limited.ResetAfter)))
+			if !limited.Allowed {
+				if limited.RetryAfter >= 0 {
+					header.Set(HeaderRetryAfter, strconv.Itoa(seconds(limited.RetryAfter)))
+				}
+				return JSON(c).Code(StatusTooManyRequests).Go()
+			}
+			// Success
+			return next(c)
+		}
+	}
+}
+
+// seconds rounds up so clients never retry too early
+func seconds(d clock.Duration) int {
+	// Success
+	return int(math.Ceil(d.Seconds()))
+}
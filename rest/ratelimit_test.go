@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/h14yhv/golang-lib/clock"
+	"github.com/h14yhv/golang-lib/ratelimit"
+)
+
+type fakeLimiter struct {
+	keys   []string
+	result ratelimit.Result
+	err    error
+}
+
+func (l *fakeLimiter) Allow(key string) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	// Success
+	return l.result, l.err
+}
+
+func serve(limiter ratelimit.Limiter, key func(c echo.Context) string) *httptest.ResponseRecorder {
+	e := echo.New()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = "10.0.0.1:1234"
+	recorder := httptest.NewRecorder()
+	handler := RateLimit(limiter, key)(func(c echo.Context) error {
+		return c.NoContent(StatusNoContent)
+	})
+	if err := handler(e.NewContext(request, recorder)); err != nil {
+		e.HTTPErrorHandler(err, e.NewContext(request, recorder))
+	}
+	// Success
+	return recorder
+}
+
+func TestRateLimitAllowed(t *testing.T) {
+	limiter := &fakeLimiter{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 9, ResetAfter: 1500 * clock.Millisecond}}
+	recorder := serve(limiter, nil)
+	if recorder.Code != StatusNoContent {
+		t.Fatalf("status %d, want %d", recorder.Code, StatusNoContent)
+	}
+	if len(limiter.keys) != 1 || limiter.keys[0] != "10.0.0.1" {
+		t.Fatalf("limited keys %v, want the client IP", limiter.keys)
+	}
+	header := recorder.Header()
+	if header.Get(HeaderXRateLimitLimit) != "10" || header.Get(HeaderXRateLimitRemaining) != "9" || header.Get(HeaderXRateLimitReset) != "2" {
+		t.Fatalf("rate limit headers %v", header)
+	}
+	if header.Get(HeaderRetryAfter) != "" {
+		t.Fatalf("allowed request has a %s header", HeaderRetryAfter)
+	}
+}
+
+func TestRateLimitDenied(t *testing.T) {
+	limiter := &fakeLimiter{result: ratelimit.Result{Limit: 10, ResetAfter: clock.Minute, RetryAfter: 100 * clock.Millisecond}}
+	recorder := serve(limiter, func(c echo.Context) string { return "user" })
+	if recorder.Code != StatusTooManyRequests {
+		t.Fatalf("status %d, want %d", recorder.Code, StatusTooManyRequests)
+	}
+	if limiter.keys[0] != "user" {
+		t.Fatalf("limited key %q, want the key function result", limiter.keys[0])
+	}
+	if retry := recorder.Header().Get(HeaderRetryAfter); retry != "1" {
+		t.Fatalf("%s is %q, want 1", HeaderRetryAfter, retry)
+	}
+	limiter.result.RetryAfter = -1
+	if recorder = serve(limiter, nil); recorder.Header().Get(HeaderRetryAfter) != "" {
+		t.Fatalf("a cost over the limit has a %s header", HeaderRetryAfter)
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	limiter := &fakeLimiter{err: errors.New("unavailable")}
+	if recorder := serve(limiter, nil); recorder.Code != StatusNoContent {
+		t.Fatalf("status %d on a limiter error, want %d", recorder.Code, StatusNoContent)
+	}
+}